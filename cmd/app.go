@@ -1,16 +1,21 @@
 package cmd
 
 import (
+	"flag"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/mitchellh/cli"
 
 	// These are lua wrapped code that will be made accessible to lua
 	_ "github.com/tanema/amore/audio"
+	_ "github.com/tanema/amore/file/wrap"
 	_ "github.com/tanema/amore/gfx/wrap"
 	_ "github.com/tanema/amore/input"
+	_ "github.com/tanema/amore/timer"
 
+	"github.com/tanema/amore/file"
 	"github.com/tanema/amore/runtime"
 )
 
@@ -29,8 +34,12 @@ var ui = &cli.BasicUi{
 }
 
 var commands = map[string]cli.CommandFactory{
-	"":    func() (cli.Command, error) { return &runCommand{ui: ui}, nil },
-	"run": func() (cli.Command, error) { return &runCommand{ui: ui}, nil },
+	"":       func() (cli.Command, error) { return &runCommand{ui: ui}, nil },
+	"run":    func() (cli.Command, error) { return &runCommand{ui: ui}, nil },
+	"replay": func() (cli.Command, error) { return &replayCommand{ui: ui}, nil },
+	"bundle": func() (cli.Command, error) { return &bundleCommand{ui: ui}, nil },
+	"new":    func() (cli.Command, error) { return &newCommand{ui: ui}, nil },
+	"test":   func() (cli.Command, error) { return &testCommand{ui: ui}, nil },
 }
 
 type runCommand struct {
@@ -38,7 +47,56 @@ type runCommand struct {
 }
 
 func (run *runCommand) Run(args []string) int {
-	if err := runtime.Run("main.lua"); err != nil {
+	var record, logLevel string
+	var opts runtime.Options
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.StringVar(&record, "record", "", "")
+	flags.StringVar(&logLevel, "log", "warn", "")
+	flags.IntVar(&opts.Width, "width", 0, "")
+	flags.IntVar(&opts.Height, "height", 0, "")
+	flags.BoolVar(&opts.Fullscreen, "fullscreen", false, "")
+	flags.BoolVar(&opts.Headless, "headless", false, "")
+	flags.BoolVar(&opts.HeadlessDraw, "headless-draw", false, "")
+	flags.StringVar(&opts.Events, "events", "", "")
+	flags.IntVar(&opts.Frames, "frames", 0, "")
+	flags.BoolVar(&opts.Watch, "watch", false, "")
+	flags.BoolVar(&opts.Console, "console", false, "")
+	flags.BoolVar(&opts.Stats, "stats", false, "")
+	flags.StringVar(&opts.Profile, "profile", "", "")
+	flags.Usage = func() { run.ui.Output(run.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if err := runtime.SetLogLevel(logLevel); err != nil {
+		run.ui.Error(err.Error())
+		return 1
+	}
+	for _, path := range []*string{&record, &opts.Events, &opts.Profile} {
+		if *path == "" {
+			continue
+		}
+		var err error
+		if *path, err = filepath.Abs(*path); err != nil {
+			run.ui.Error(err.Error())
+			return 1
+		}
+	}
+	if flags.NArg() > 0 {
+		if err := openGame(flags.Arg(0)); err != nil {
+			run.ui.Error(err.Error())
+			return 1
+		}
+	}
+	runtime.SetOptions(opts)
+
+	var err error
+	if record != "" {
+		err = runtime.Record("main.lua", record)
+	} else {
+		err = runtime.Run("main.lua")
+	}
+	if err != nil {
 		run.ui.Error(err.Error())
 		return 1
 	}
@@ -46,13 +104,71 @@ func (run *runCommand) Run(args []string) int {
 }
 
 func (run *runCommand) Synopsis() string {
-	return ""
+	return "Run a game directory or archive"
 }
 
 func (run *runCommand) Help() string {
 	helpText := `
-Usage: moony
-Run your program yo
+Usage: moony run [options] [dir|archive]
+Run the game in the directory, or in an archive made with moony bundle. If
+neither is given the game in the current directory is run.
+Options:
+  -h, --help       show this help
+  --width <w>      override the window width set in conf.lua
+  --height <h>     override the window height set in conf.lua
+  --fullscreen     start the window in fullscreen
+  --headless       run without a window, graphics calls do nothing
+  --headless-draw  call draw when running headless
+  --events <file>  feed the input events in file to the program
+  --frames <n>     quit after n frames
+  --watch          reload scripts and assets when they change
+  --console        enable the developer console, toggled with the grave key
+  --stats          show frame stats over the game, toggled with F3
+  --profile <file> write a chrome trace of the phases of every frame to file
+  --log <level>    log level, one of debug, info, warn, error or none. Defaults to warn
+  --record <file>  record all input and timesteps to file for replaying
+`
+	return strings.TrimSpace(helpText)
+}
+
+// openGame will change to the game directory so that all of its files can be
+// found, or mount the archive if the path is a file.
+func openGame(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return os.Chdir(path)
+	}
+	return file.Mount(path)
+}
+
+type replayCommand struct {
+	ui cli.Ui
+}
+
+func (replay *replayCommand) Run(args []string) int {
+	if len(args) != 1 {
+		replay.ui.Error(replay.Help())
+		return 1
+	}
+	if err := runtime.Replay(args[0]); err != nil {
+		replay.ui.Error(err.Error())
+		return 1
+	}
+	return 0
+}
+
+func (replay *replayCommand) Synopsis() string {
+	return "Replay a recording made with moony run --record"
+}
+
+func (replay *replayCommand) Help() string {
+	helpText := `
+Usage: moony replay <file>
+Replay a recording made with moony run --record. The same input and timesteps
+are fed back into your program without a window.
 Options:
   -h, --help  show this help
 `
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+// globList is a flag that can be given multiple times to collect globs
+type globList []string
+
+func (globs *globList) String() string { return strings.Join(*globs, ",") }
+
+func (globs *globList) Set(value string) error {
+	*globs = append(*globs, value)
+	return nil
+}
+
+type bundleCommand struct {
+	ui cli.Ui
+}
+
+func (bundle *bundleCommand) Run(args []string) int {
+	var output, pkg string
+	var include, exclude globList
+	flags := flag.NewFlagSet("bundle", flag.ContinueOnError)
+	flags.StringVar(&output, "o", "", "")
+	flags.StringVar(&pkg, "pkg", "main", "")
+	flags.Var(&include, "include", "")
+	flags.Var(&exclude, "exclude", "")
+	flags.Usage = func() { bundle.ui.Output(bundle.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	if output == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			bundle.ui.Error(err.Error())
+			return 1
+		}
+		output = filepath.Base(abs) + ".moony"
+	}
+
+	if rel, err := filepath.Rel(dir, output); err == nil {
+		exclude = append(exclude, filepath.ToSlash(rel))
+	}
+	data, err := bundleDir(dir, include, exclude)
+	if err != nil {
+		bundle.ui.Error(err.Error())
+		return 1
+	}
+	if filepath.Ext(output) == ".go" {
+		data = bundleSource(pkg, data)
+	}
+	if err := ioutil.WriteFile(output, data, 0644); err != nil {
+		bundle.ui.Error(err.Error())
+		return 1
+	}
+	bundle.ui.Output(fmt.Sprintf("bundled %v into %v", dir, output))
+	return 0
+}
+
+func (bundle *bundleCommand) Synopsis() string {
+	return "Bundle a game directory into a single archive or go file"
+}
+
+func (bundle *bundleCommand) Help() string {
+	helpText := `
+Usage: moony bundle [options] [dir]
+Bundle all the files in dir, or the current directory, into a single archive.
+The archive can be run with moony run <archive>. If the output ends in .go a go
+source file is generated instead that registers the files when it is compiled
+into your program.
+Options:
+  -h, --help          show this help
+  -o <file>           output file, .zip, .moony or .go. Defaults to <dir>.moony
+  --pkg <name>        package name of the generated go file. Defaults to main
+  --include <glob>    only bundle files matching the glob, can be given many times
+  --exclude <glob>    skip files matching the glob, can be given many times
+`
+	return strings.TrimSpace(helpText)
+}
+
+// bundleDir will walk the directory and zip all the files that match the include
+// globs and do not match the exclude globs. Hidden files are always excluded.
+// The files are stored relative to the directory so that files under assets/
+// keep the prefix that file.Register expects.
+func bundleDir(dir string, include, exclude []string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	archive := zip.NewWriter(buf)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(info.Name(), ".") || matchGlobs(exclude, rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || (len(include) > 0 && !matchGlobs(include, rel)) {
+			return nil
+		}
+		return bundleFile(archive, path, rel, info)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func bundleFile(archive *zip.Writer, path, name string, info os.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(writer, src)
+	return err
+}
+
+// matchGlobs will return true if the path or its file name matches any of the
+// globs, or if the path is within a directory that matches.
+func matchGlobs(globs []string, path string) bool {
+	for _, glob := range globs {
+		glob = strings.TrimSuffix(filepath.ToSlash(glob), "/")
+		if ok, _ := filepath.Match(glob, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(glob, filepath.Base(path)); ok {
+			return true
+		}
+		if strings.HasPrefix(path, glob+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleSource will generate a go source file that registers the bundled data
+func bundleSource(pkg string, data []byte) []byte {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Code generated by moony bundle. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "package %v\n\n", pkg)
+	fmt.Fprintf(buf, "import \"github.com/tanema/amore/file\"\n\n")
+	fmt.Fprintf(buf, "func init() {\n\tfile.Register(%q)\n}\n", data)
+	return buf.Bytes()
+}
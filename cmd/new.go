@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+const mainTemplate = `function onload()
+end
+
+function oninput(device, button, action, modifiers)
+  if device == "keyboard" and button == "escape" and action == "release" then
+    quit()
+  end
+end
+
+function update(dt)
+end
+
+function draw()
+  gfx.print(%v, 10, 10)
+end
+`
+
+const confTemplate = `function conf(t)
+  t.identity = %v
+  t.window.title = %v
+  t.window.width = 800
+  t.window.height = 600
+end
+`
+
+type newCommand struct {
+	ui cli.Ui
+}
+
+func (create *newCommand) Run(args []string) int {
+	if len(args) != 1 {
+		create.ui.Error(create.Help())
+		return 1
+	}
+
+	dir := args[0]
+	if _, err := os.Stat(dir); err == nil {
+		create.ui.Error(fmt.Sprintf("%v already exists", dir))
+		return 1
+	}
+	name := luaQuote(filepath.Base(dir))
+
+	if err := os.MkdirAll(filepath.Join(dir, "assets"), 0755); err != nil {
+		create.ui.Error(err.Error())
+		return 1
+	}
+	files := map[string]string{
+		"main.lua": fmt.Sprintf(mainTemplate, name),
+		"conf.lua": fmt.Sprintf(confTemplate, name, name),
+	}
+	for filename, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, filename), []byte(content), 0644); err != nil {
+			create.ui.Error(err.Error())
+			return 1
+		}
+	}
+	create.ui.Output(fmt.Sprintf("created %v, run it with moony run %v", dir, dir))
+	return 0
+}
+
+// luaQuote will quote the string as a lua string literal so that directory
+// names with quotes or backslashes cannot break the generated files.
+func luaQuote(str string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for _, b := range []byte(str) {
+		switch {
+		case b == '"' || b == '\\':
+			quoted.WriteByte('\\')
+			quoted.WriteByte(b)
+		case b < ' ' || b == 0x7f:
+			fmt.Fprintf(&quoted, "\\%03d", b)
+		default:
+			quoted.WriteByte(b)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+func (create *newCommand) Synopsis() string {
+	return "Create a new game project"
+}
+
+func (create *newCommand) Help() string {
+	helpText := `
+Usage: moony new <name>
+Create a new game in the directory name with a main.lua, conf.lua and an assets
+directory to get started.
+Options:
+  -h, --help  show this help
+`
+	return strings.TrimSpace(helpText)
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+
+	"github.com/tanema/amore/runtime"
+)
+
+type testCommand struct {
+	ui cli.Ui
+}
+
+func (test *testCommand) Run(args []string) int {
+	var verbose bool
+	var opts runtime.TestOptions
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.BoolVar(&verbose, "v", false, "")
+	flags.StringVar(&opts.Match, "run", "", "")
+	flags.BoolVar(&opts.Render, "gl", false, "")
+	flags.BoolVar(&opts.Update, "update", false, "")
+	flags.Usage = func() { test.ui.Output(test.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if flags.NArg() > 0 {
+		if err := os.Chdir(flags.Arg(0)); err != nil {
+			test.ui.Error(err.Error())
+			return 1
+		}
+	}
+	paths, err := findTests(".")
+	if err != nil {
+		test.ui.Error(err.Error())
+		return 1
+	}
+	if len(paths) == 0 {
+		test.ui.Output("no test files found")
+		return 0
+	}
+
+	start := time.Now()
+	results, err := runtime.RunTests(paths, opts)
+	if err != nil {
+		test.ui.Error(err.Error())
+		return 1
+	}
+
+	failed, skipped := 0, 0
+	for _, result := range results {
+		name := result.File
+		if result.Name != "" {
+			name += " " + result.Name
+		}
+		if result.Skipped {
+			skipped++
+			if verbose {
+				test.ui.Output(fmt.Sprintf("SKIP %v: %v", name, result.Message))
+			}
+			continue
+		} else if result.Passed {
+			if verbose {
+				test.ui.Output(fmt.Sprintf("PASS %v (%.2fs)", name, result.Duration.Seconds()))
+			}
+			continue
+		}
+		failed++
+		test.ui.Error(fmt.Sprintf("FAIL %v (%.2fs)", name, result.Duration.Seconds()))
+		test.ui.Error(indent(result.Message))
+		if result.Trace != "" {
+			test.ui.Error(indent(result.Trace))
+		}
+	}
+
+	summary := fmt.Sprintf("%v passed, %v failed", len(results)-failed-skipped, failed)
+	if skipped > 0 {
+		summary += fmt.Sprintf(", %v skipped", skipped)
+	}
+	summary += fmt.Sprintf(" (%.2fs)", time.Since(start).Seconds())
+	if failed > 0 {
+		test.ui.Error("FAIL " + summary)
+		return 1
+	}
+	test.ui.Output("ok " + summary)
+	return 0
+}
+
+func (test *testCommand) Synopsis() string {
+	return "Run the lua tests of a game"
+}
+
+func (test *testCommand) Help() string {
+	helpText := `
+Usage: moony test [options] [dir]
+Run all the tests in the *_test.lua files in dir, or the current directory. Every
+global function starting with test is a test and is run headless in a fresh state
+with the test file loaded. A test module is available with assertions, like
+test.equal(actual, expected), and test.frames(n, dt) to simulate frames of the
+game with input pushed by test.press, test.release, test.text and test.event.
+test.image(path, w, h, fn, tolerance, maxdiff) compares what fn draws to a golden
+png image, which is written by running with --update. maxdiff is how many pixels
+may differ, or a ratio of all the pixels if less than 1. Images are only rendered
+with --gl, which needs moony built with -tags osmesa, otherwise those tests are
+skipped.
+Options:
+  -h, --help     show this help
+  -v             list passing and skipped tests as well as failing ones
+  --run <name>   only run tests with a name containing name
+  --gl           render with a software gl context so images can be compared
+  --update       write rendered images over the golden images
+`
+	return strings.TrimSpace(helpText)
+}
+
+// findTests will return the paths of all the test files in the directory. Hidden
+// directories are skipped.
+func findTests(dir string) ([]string, error) {
+	paths := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), "_test.lua") {
+			paths = append(paths, filepath.ToSlash(path))
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func indent(text string) string {
+	return "    " + strings.Replace(text, "\n", "\n    ", -1)
+}
@@ -4,8 +4,11 @@ package file
 
 import (
 	"archive/zip"
+	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -24,6 +27,25 @@ func Register(data string) {
 	if err != nil {
 		panic(err)
 	}
+	registerZip(zipReader)
+}
+
+// Mount will read the archive at the path on disk, created by moony bundle, and
+// register all of its files as if they had been bundled into the program.
+func Mount(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("could not mount %v: %v", path, err)
+	}
+	registerZip(zipReader)
+	return nil
+}
+
+func registerZip(zipReader *zip.Reader) {
 	for _, file := range zipReader.File {
 		zipFiles[file.Name] = file
 	}
@@ -32,14 +54,7 @@ func Register(data string) {
 // Read will read a file at the path specified in total and return a byte
 // array of the file contents
 func Read(path string) ([]byte, error) {
-	path = normalizePath(path)
-	var file io.ReadCloser
-	var err error
-	if zipfile, ok := zipFiles[path]; ok {
-		file, err = zipfile.Open()
-	} else {
-		file, err = Open(path)
-	}
+	file, err := Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -57,10 +72,16 @@ func ReadString(filename string) string {
 	return string(s[:])
 }
 
-// Open will return the file if its bundled or on disk and return a File interface
-// for the file and an error if it does not exist. The File interface allows for
-// consitent access to disk files and zip files.
+// Open will return the file if its in the save directory, bundled or on disk and
+// return a File interface for the file and an error if it does not exist. The
+// save directory is searched first so that saved files can override assets. The
+// File interface allows for consitent access to disk files and zip files.
 func Open(path string) (io.ReadCloser, error) {
+	if fullpath, err := savePath(path); err == nil {
+		if file, err := os.Open(fullpath); err == nil {
+			return file, nil
+		}
+	}
 	path = normalizePath(path)
 	zipFile, ok := zipFiles[path]
 	if !ok {
@@ -0,0 +1,237 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Info describes a file or directory in the save directory, the bundle or the
+// source directory.
+type Info struct {
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+var (
+	identity string
+	saveDir  string
+
+	errNoSaveDir = errors.New("no save directory, the identity has not been set")
+)
+
+// SetIdentity will set the name of the game which is used as the name of the save
+// directory. The save directory is created in the data directory of the user,
+// $XDG_DATA_HOME or ~/.local/share on linux. It is only created once something
+// is written to it.
+func SetIdentity(name string) {
+	identity = name
+	saveDir = ""
+	if name == "" {
+		return
+	}
+	if dataDir := userDataDir(); dataDir != "" {
+		saveDir = filepath.Join(dataDir, name)
+	}
+}
+
+// GetIdentity will return the name of the game used for the save directory
+func GetIdentity() string {
+	return identity
+}
+
+// SetSaveDirectory will use dir as the save directory instead of the one named by
+// the identity, like a temporary directory when running tests.
+func SetSaveDirectory(dir string) {
+	saveDir = dir
+}
+
+// GetSaveDirectory will return the full path to the save directory, or an empty
+// string if there is no save directory available.
+func GetSaveDirectory() string {
+	return saveDir
+}
+
+// Write will write the data to the file at the path in the save directory,
+// creating or truncating the file.
+func Write(path string, data []byte) error {
+	return writeFile(path, data, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+}
+
+// Append will add the data to the end of the file at the path in the save
+// directory, creating the file if it does not exist.
+func Append(path string, data []byte) error {
+	return writeFile(path, data, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+}
+
+// Mkdir will create the directory and any parent directories at the path in the
+// save directory.
+func Mkdir(path string) error {
+	fullpath, err := savePath(path)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(fullpath, 0755)
+}
+
+// Remove will remove the file or empty directory at the path in the save directory.
+func Remove(path string) error {
+	fullpath, err := savePath(path)
+	if err != nil {
+		return err
+	}
+	return os.Remove(fullpath)
+}
+
+// List will return the names of all the files and directories in the directory at
+// the path, merged from the save directory, the bundle and the source directory.
+func List(dir string) ([]string, error) {
+	dir = strings.Trim(cleanPath(dir), "/")
+	found := map[string]bool{}
+
+	if fullpath, err := savePath(dir); err == nil {
+		listDir(fullpath, found)
+	}
+
+	prefix := dir + "/"
+	if dir == "" {
+		prefix = ""
+	}
+	for name := range zipFiles {
+		if !strings.HasPrefix(name, prefix) || name == prefix {
+			continue
+		}
+		entry := strings.SplitN(strings.TrimPrefix(name, prefix), "/", 2)[0]
+		found[entry] = true
+	}
+
+	if dir == "" {
+		listDir(".", found)
+	} else {
+		listDir(dir, found)
+	}
+
+	if len(found) == 0 && dir != "" && !Exists(dir) {
+		return nil, os.ErrNotExist
+	}
+	names := []string{}
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GetInfo will return the size and modification time of the file or directory
+// at the path, looking in the save directory, the bundle then the source directory.
+func GetInfo(path string) (Info, error) {
+	if fullpath, err := savePath(path); err == nil {
+		if stat, err := os.Stat(fullpath); err == nil {
+			return statInfo(stat), nil
+		}
+	}
+	path = normalizePath(cleanPath(path))
+	if zipfile, ok := zipFiles[path]; ok {
+		return Info{
+			Size:    int64(zipfile.UncompressedSize64),
+			ModTime: zipfile.Modified,
+			IsDir:   zipfile.FileInfo().IsDir(),
+		}, nil
+	}
+	for name := range zipFiles {
+		if strings.HasPrefix(name, path+"/") {
+			return Info{IsDir: true}, nil
+		}
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return Info{}, err
+	}
+	return statInfo(stat), nil
+}
+
+// Exists will return true if the file or directory exists in the save directory,
+// the bundle or the source directory.
+func Exists(path string) bool {
+	_, err := GetInfo(path)
+	return err == nil
+}
+
+func statInfo(stat os.FileInfo) Info {
+	return Info{Size: stat.Size(), ModTime: stat.ModTime(), IsDir: stat.IsDir()}
+}
+
+func listDir(dir string, found map[string]bool) {
+	dirFile, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer dirFile.Close()
+	names, _ := dirFile.Readdirnames(-1)
+	for _, name := range names {
+		found[name] = true
+	}
+}
+
+func writeFile(path string, data []byte, flag int) error {
+	fullpath, err := savePath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullpath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fullpath, flag, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// savePath will return the full path to a file in the save directory. The path is
+// cleaned so that it cannot refer to anything outside of the save directory.
+func savePath(path string) (string, error) {
+	if saveDir == "" {
+		return "", errNoSaveDir
+	}
+	return filepath.Join(saveDir, filepath.FromSlash(cleanPath(path))), nil
+}
+
+// cleanPath will resolve any relative elements in a path while keeping it rooted
+// so that .. cannot escape the directory it is relative to.
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+// userDataDir will return the directory that application data should be saved in
+// for the current platform.
+func userDataDir() string {
+	switch goruntime.GOOS {
+	case "windows":
+		return os.Getenv("APPDATA")
+	case "darwin":
+		if home := os.Getenv("HOME"); home != "" {
+			return filepath.Join(home, "Library", "Application Support")
+		}
+	case "js":
+		return ""
+	default:
+		if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+			return dataHome
+		}
+		if home := os.Getenv("HOME"); home != "" {
+			return filepath.Join(home, ".local", "share")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+// Package wrap exposes the file package to lua. Writes are limited to the save
+// directory while reads search the save directory, the bundle and then the
+// source directory.
+package wrap
+
+import (
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/file"
+	"github.com/tanema/amore/runtime"
+)
+
+var fileFunctions = runtime.LuaFuncs{
+	"read":             fileRead,
+	"write":            fileWrite,
+	"append":           fileAppend,
+	"mkdir":            fileMkdir,
+	"remove":           fileRemove,
+	"list":             fileList,
+	"getinfo":          fileGetInfo,
+	"exists":           fileExists,
+	"getidentity":      fileGetIdentity,
+	"getsavedirectory": fileGetSaveDirectory,
+}
+
+func init() {
+	runtime.RegisterModule("file", fileFunctions, nil)
+}
+
+func fileRead(ls *lua.LState) int {
+	data, err := file.Read(ls.CheckString(1))
+	if err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(lua.LString(data))
+	return 1
+}
+
+func fileWrite(ls *lua.LState) int {
+	return returnResult(ls, file.Write(ls.CheckString(1), []byte(ls.CheckString(2))))
+}
+
+func fileAppend(ls *lua.LState) int {
+	return returnResult(ls, file.Append(ls.CheckString(1), []byte(ls.CheckString(2))))
+}
+
+func fileMkdir(ls *lua.LState) int {
+	return returnResult(ls, file.Mkdir(ls.CheckString(1)))
+}
+
+func fileRemove(ls *lua.LState) int {
+	return returnResult(ls, file.Remove(ls.CheckString(1)))
+}
+
+func fileList(ls *lua.LState) int {
+	names, err := file.List(ls.OptString(1, ""))
+	if err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	table := ls.NewTable()
+	for _, name := range names {
+		table.Append(lua.LString(name))
+	}
+	ls.Push(table)
+	return 1
+}
+
+func fileGetInfo(ls *lua.LState) int {
+	info, err := file.GetInfo(ls.CheckString(1))
+	if err != nil {
+		ls.Push(lua.LNil)
+		return 1
+	}
+	table := ls.NewTable()
+	table.RawSetString("size", lua.LNumber(info.Size))
+	table.RawSetString("modtime", lua.LNumber(info.ModTime.Unix()))
+	if info.IsDir {
+		table.RawSetString("type", lua.LString("directory"))
+	} else {
+		table.RawSetString("type", lua.LString("file"))
+	}
+	ls.Push(table)
+	return 1
+}
+
+func fileExists(ls *lua.LState) int {
+	ls.Push(lua.LBool(file.Exists(ls.CheckString(1))))
+	return 1
+}
+
+func fileGetIdentity(ls *lua.LState) int {
+	ls.Push(lua.LString(file.GetIdentity()))
+	return 1
+}
+
+func fileGetSaveDirectory(ls *lua.LState) int {
+	ls.Push(lua.LString(file.GetSaveDirectory()))
+	return 1
+}
+
+func returnResult(ls *lua.LState, err error) int {
+	if err != nil {
+		ls.Push(lua.LFalse)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(lua.LTrue)
+	return 1
+}
@@ -0,0 +1,538 @@
+package gfx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/goxjs/gl"
+
+	"github.com/tanema/amore/file"
+)
+
+// defaultFrameDuration is the duration in seconds of frames that were not given one
+const defaultFrameDuration = 0.1
+
+// Animation plays the frames of a sprite sheet. Each frame is a quad of the
+// texture with its own duration and the animation is advanced by calling Update
+// with the timestep. Tags name ranges of frames, like walk or jump, so that one
+// sheet can hold every animation of a sprite.
+type Animation struct {
+	texture   ITexture
+	frames    []animationFrame
+	tags      map[string]AnimationTag
+	tag       string        // current tag, empty if all the frames are played
+	from, to  int           // range of frames being played
+	mode      AnimationMode // what happens when the end of the range is reached
+	reverse   bool          // play the range from the last frame to the first
+	frame     int           // current frame
+	direction int           // 1 while playing forward and -1 while playing backward
+	time      float32       // time the current frame has been shown
+	playing   bool
+	flipX     bool
+	flipY     bool
+	onFrame   interface{} // called with the frame by the runner given to update
+	onLoop    interface{} // called by the runner given to update
+}
+
+// AnimationCallbackRunner is given to Update to call the callbacks of the
+// animation, with the frame index for frame callbacks and nothing for loop
+// callbacks, so that they can be run in the state that is updating it.
+type AnimationCallbackRunner func(callback interface{}, args ...int) error
+
+// AnimationTag is a named range of frames of an animation, From and To inclusive.
+// When the tag is set the animation plays with its mode and direction.
+type AnimationTag struct {
+	From, To int
+	Mode     AnimationMode
+	Reverse  bool
+}
+
+// animationFrame is a quad of the sprite sheet and how long it is shown. Frames
+// trimmed of transparent pixels are offset by x, y inside of the original width
+// and height so that they line up with the other frames and flip around the same
+// center.
+type animationFrame struct {
+	quad          *Quad
+	duration      float32
+	x, y          float32
+	width, height float32
+}
+
+// animationSheet is the json that Aseprite and TexturePacker export with a sprite
+// sheet. Aseprite adds a duration to each frame and tags to the meta.
+type animationSheet struct {
+	Frames sheetFrames `json:"frames"`
+	Meta   struct {
+		Image     string               `json:"image"`
+		Size      struct{ W, H int32 } `json:"size"`
+		FrameTags []struct {
+			Name      string `json:"name"`
+			From      int    `json:"from"`
+			To        int    `json:"to"`
+			Direction string `json:"direction"`
+		} `json:"frameTags"`
+	} `json:"meta"`
+}
+
+// sheetFrame is a single frame of an exported sprite sheet
+type sheetFrame struct {
+	Frame            struct{ X, Y, W, H int32 } `json:"frame"`
+	Rotated          bool                       `json:"rotated"`
+	SpriteSourceSize struct{ X, Y int32 }       `json:"spriteSourceSize"`
+	SourceSize       struct{ W, H float32 }     `json:"sourceSize"`
+	Duration         float32                    `json:"duration"` // milliseconds
+}
+
+// sheetFrames are the frames of an exported sprite sheet. They are exported as
+// either an array or an object keyed by file name.
+type sheetFrames []sheetFrame
+
+// UnmarshalJSON will decode frames exported as an array or an object, keeping the
+// order that the frames of an object are in.
+func (frames *sheetFrames) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return json.Unmarshal(data, (*[]sheetFrame)(frames))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	for decoder.More() {
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+		var frame sheetFrame
+		if err := decoder.Decode(&frame); err != nil {
+			return err
+		}
+		*frames = append(*frames, frame)
+	}
+	return nil
+}
+
+// NewAnimation will create an animation that plays the quads of the texture in
+// order. Durations are in seconds, either one for every quad or a single duration
+// for all of them. If no durations are given each frame is shown for 0.1 seconds.
+func NewAnimation(texture ITexture, quads []*Quad, durations []float32) (*Animation, error) {
+	if len(quads) == 0 {
+		return nil, fmt.Errorf("an animation needs at least one frame")
+	} else if len(durations) > 1 && len(durations) != len(quads) {
+		return nil, fmt.Errorf("expected 1 or %v durations but got %v", len(quads), len(durations))
+	}
+	frames := make([]animationFrame, len(quads))
+	for i, quad := range quads {
+		duration := float32(defaultFrameDuration)
+		if len(durations) == 1 {
+			duration = durations[0]
+		} else if len(durations) > 1 {
+			duration = durations[i]
+		}
+		frames[i] = animationFrame{quad: quad, duration: duration, width: quad.w, height: quad.h}
+	}
+	return newAnimation(texture, frames), nil
+}
+
+// NewGridAnimation will create an animation of a sprite sheet laid out as a grid
+// of frames that are all frameWidth by frameHeight. Cells of the grid are numbered
+// from 0 at the top left along each row, and frames lists the cells that are
+// played. If no frames are given every cell is played in order.
+func NewGridAnimation(texture ITexture, frameWidth, frameHeight int32, frames []int, durations []float32) (*Animation, error) {
+	if frameWidth <= 0 || frameHeight <= 0 {
+		return nil, fmt.Errorf("invalid frame size %vx%v", frameWidth, frameHeight)
+	}
+	width, height := texture.GetWidth(), texture.GetHeight()
+	columns, rows := int(width/frameWidth), int(height/frameHeight)
+	if columns == 0 || rows == 0 {
+		return nil, fmt.Errorf("frame size %vx%v is larger than the texture", frameWidth, frameHeight)
+	}
+	if len(frames) == 0 {
+		for cell := 0; cell < columns*rows; cell++ {
+			frames = append(frames, cell)
+		}
+	}
+	quads := make([]*Quad, len(frames))
+	for i, cell := range frames {
+		if cell < 0 || cell >= columns*rows {
+			return nil, fmt.Errorf("frame %v is outside of the %vx%v grid", cell, columns, rows)
+		}
+		x, y := int32(cell%columns)*frameWidth, int32(cell/columns)*frameHeight
+		quads[i] = NewQuad(x, y, frameWidth, frameHeight, width, height)
+	}
+	return NewAnimation(texture, quads, durations)
+}
+
+// NewAnimationFromJSON will create an animation from the json that Aseprite or
+// TexturePacker export with a sprite sheet, as either an array or a hash. The
+// frame durations and tags from Aseprite are kept, TexturePacker frames are each
+// shown for 0.1 seconds. If texture is nil the image named in the json is loaded
+// from the same directory.
+func NewAnimationFromJSON(filepath string, texture ITexture) (*Animation, error) {
+	data, err := file.Read(filepath)
+	if err != nil {
+		return nil, err
+	}
+	var sheet animationSheet
+	if err := json.Unmarshal(data, &sheet); err != nil {
+		return nil, fmt.Errorf("could not parse %v: %v", filepath, err)
+	} else if len(sheet.Frames) == 0 {
+		return nil, fmt.Errorf("%v has no frames", filepath)
+	}
+
+	if texture == nil {
+		if sheet.Meta.Image == "" {
+			return nil, fmt.Errorf("%v does not name an image", filepath)
+		}
+		texture = NewImage(path.Join(path.Dir(filepath), sheet.Meta.Image), false)
+	}
+	width, height := sheet.Meta.Size.W, sheet.Meta.Size.H
+	if width == 0 || height == 0 {
+		if img, ok := texture.(*Image); ok && img.Texture == nil {
+			return nil, fmt.Errorf("%v has no size and its image is not loaded", filepath)
+		}
+		width, height = texture.GetWidth(), texture.GetHeight()
+	}
+
+	frames := make([]animationFrame, len(sheet.Frames))
+	for i, frame := range sheet.Frames {
+		if frame.Rotated {
+			return nil, fmt.Errorf("frame %v of %v is rotated, export the sheet without rotation", i, filepath)
+		}
+		rect := frame.Frame
+		frames[i] = animationFrame{
+			quad:     NewQuad(rect.X, rect.Y, rect.W, rect.H, width, height),
+			duration: defaultFrameDuration,
+			x:        float32(frame.SpriteSourceSize.X),
+			y:        float32(frame.SpriteSourceSize.Y),
+			width:    frame.SourceSize.W,
+			height:   frame.SourceSize.H,
+		}
+		if frame.Duration > 0 {
+			frames[i].duration = frame.Duration / 1000
+		}
+		if frames[i].width == 0 || frames[i].height == 0 {
+			frames[i].width, frames[i].height = float32(rect.W), float32(rect.H)
+		}
+	}
+
+	animation := newAnimation(texture, frames)
+	for _, tag := range sheet.Meta.FrameTags {
+		newTag := AnimationTag{From: tag.From, To: tag.To, Mode: AnimationLoop}
+		switch tag.Direction {
+		case "reverse":
+			newTag.Reverse = true
+		case "pingpong":
+			newTag.Mode = AnimationPingPong
+		case "pingpong_reverse":
+			newTag.Mode, newTag.Reverse = AnimationPingPong, true
+		}
+		if err := animation.AddTag(tag.Name, newTag); err != nil {
+			return nil, fmt.Errorf("%v: %v", filepath, err)
+		}
+	}
+	return animation, nil
+}
+
+// newAnimation will create an animation that loops through all of the frames
+func newAnimation(texture ITexture, frames []animationFrame) *Animation {
+	animation := &Animation{
+		texture: texture,
+		frames:  frames,
+		tags:    map[string]AnimationTag{},
+		to:      len(frames) - 1,
+		mode:    AnimationLoop,
+	}
+	animation.Restart()
+	return animation
+}
+
+// Update will advance the animation by dt seconds, moving through as many frames
+// as dt covers. Frames with no duration are shown until the frame is changed.
+// Callbacks are called with run and an error is returned if one fails.
+func (animation *Animation) Update(dt float32, run AnimationCallbackRunner) error {
+	if !animation.playing {
+		return nil
+	}
+	animation.time += dt
+	for animation.playing {
+		duration := animation.frames[animation.frame].duration
+		if duration <= 0 || animation.time < duration {
+			return nil
+		}
+		animation.time -= duration
+		if err := animation.advance(run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// advance will move to the next frame in the direction of play. At the end of the
+// range the animation loops, bounces or stops depending on its mode.
+func (animation *Animation) advance(run AnimationCallbackRunner) error {
+	next := animation.frame + animation.direction
+	if next >= animation.from && next <= animation.to {
+		animation.frame = next
+		return animation.callFrame(run)
+	}
+
+	switch animation.mode {
+	case AnimationOnce:
+		animation.playing = false
+		animation.time = 0
+		return animation.callLoop(run)
+	case AnimationPingPong:
+		animation.direction = -animation.direction
+		if next = animation.frame + animation.direction; next >= animation.from && next <= animation.to {
+			animation.frame = next
+		}
+	default:
+		if animation.frame = animation.from; animation.direction < 0 {
+			animation.frame = animation.to
+		}
+	}
+	// the loop callback may change the animation, like setting another tag, so the
+	// frame callback is given whatever frame is current after it.
+	if err := animation.callLoop(run); err != nil {
+		return err
+	}
+	return animation.callFrame(run)
+}
+
+func (animation *Animation) callFrame(run AnimationCallbackRunner) error {
+	if animation.onFrame == nil {
+		return nil
+	}
+	return run(animation.onFrame, animation.frame)
+}
+
+func (animation *Animation) callLoop(run AnimationCallbackRunner) error {
+	if animation.onLoop == nil {
+		return nil
+	}
+	return run(animation.onLoop)
+}
+
+// SetFrameCallback will set a callback that update runs with the index of the
+// frame every time that it moves the animation to a frame. Pass nil to remove it.
+func (animation *Animation) SetFrameCallback(callback interface{}) {
+	animation.onFrame = callback
+}
+
+// SetLoopCallback will set a callback that update runs every time that it
+// reaches the end of the frames being played, when the animation loops, bounces or
+// finishes. Pass nil to remove it.
+func (animation *Animation) SetLoopCallback(callback interface{}) {
+	animation.onLoop = callback
+}
+
+// SetMode will set what the animation does when it reaches the end of its frames
+func (animation *Animation) SetMode(mode AnimationMode) {
+	animation.mode = mode
+}
+
+// GetMode will return what the animation does when it reaches the end of its frames
+func (animation *Animation) GetMode() AnimationMode {
+	return animation.mode
+}
+
+// AddTag will name a range of frames so that it can be played with SetTag. Adding
+// a tag with a name that is already used replaces it.
+func (animation *Animation) AddTag(name string, tag AnimationTag) error {
+	if name == "" {
+		return fmt.Errorf("tags need a name")
+	} else if tag.From < 0 || tag.To >= len(animation.frames) || tag.From > tag.To {
+		return fmt.Errorf("invalid frames %v to %v for tag %v", tag.From, tag.To, name)
+	}
+	animation.tags[name] = tag
+	return nil
+}
+
+// SetTag will play the named range of frames from its start with the mode and
+// direction of the tag. An empty name plays all of the frames forward with the
+// current mode.
+func (animation *Animation) SetTag(name string) error {
+	if name == "" {
+		animation.tag, animation.from, animation.to, animation.reverse = "", 0, len(animation.frames)-1, false
+		animation.Restart()
+		return nil
+	}
+	tag, ok := animation.tags[name]
+	if !ok {
+		return fmt.Errorf("no tag named %v", name)
+	}
+	animation.tag, animation.from, animation.to = name, tag.From, tag.To
+	animation.mode, animation.reverse = tag.Mode, tag.Reverse
+	animation.Restart()
+	return nil
+}
+
+// GetTag will return the name of the tag being played, or an empty string if all
+// of the frames are played.
+func (animation *Animation) GetTag() string {
+	return animation.tag
+}
+
+// GetTags will return the names of all the tags in alphabetical order
+func (animation *Animation) GetTags() []string {
+	names := make([]string, 0, len(animation.tags))
+	for name := range animation.tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Restart will play the animation from the start of the frames being played
+func (animation *Animation) Restart() {
+	animation.frame, animation.direction = animation.from, 1
+	if animation.reverse {
+		animation.frame, animation.direction = animation.to, -1
+	}
+	animation.time = 0
+	animation.playing = true
+}
+
+// Pause will stop update from advancing the animation
+func (animation *Animation) Pause() {
+	animation.playing = false
+}
+
+// Resume will continue a paused animation from the frame it is on. An animation
+// that played once and finished will play its last frame again and then finish.
+func (animation *Animation) Resume() {
+	animation.playing = true
+}
+
+// IsPlaying will return false if the animation is paused or has played once and
+// finished.
+func (animation *Animation) IsPlaying() bool {
+	return animation.playing
+}
+
+// GotoFrame will show a frame from the start of its duration. The frame must be
+// within the tag being played.
+func (animation *Animation) GotoFrame(frame int) error {
+	if frame < animation.from || frame > animation.to {
+		return fmt.Errorf("invalid frame %v", frame)
+	}
+	animation.frame = frame
+	animation.time = 0
+	return nil
+}
+
+// GetFrame will return the index of the frame being shown
+func (animation *Animation) GetFrame() int {
+	return animation.frame
+}
+
+// GetFrameCount will return how many frames the animation has in total
+func (animation *Animation) GetFrameCount() int {
+	return len(animation.frames)
+}
+
+// SetDuration will set how many seconds a frame is shown for
+func (animation *Animation) SetDuration(frame int, duration float32) error {
+	if frame < 0 || frame >= len(animation.frames) {
+		return fmt.Errorf("invalid frame %v", frame)
+	}
+	animation.frames[frame].duration = duration
+	return nil
+}
+
+// GetDuration will return how many seconds a frame is shown for
+func (animation *Animation) GetDuration(frame int) (float32, error) {
+	if frame < 0 || frame >= len(animation.frames) {
+		return 0, fmt.Errorf("invalid frame %v", frame)
+	}
+	return animation.frames[frame].duration, nil
+}
+
+// SetFlip will mirror the frames horizontally, vertically or both when they are
+// drawn. Frames are flipped within their own bounds so they stay in place.
+func (animation *Animation) SetFlip(x, y bool) {
+	animation.flipX, animation.flipY = x, y
+}
+
+// GetFlip will return if the frames are mirrored horizontally and vertically
+func (animation *Animation) GetFlip() (bool, bool) {
+	return animation.flipX, animation.flipY
+}
+
+// GetQuad will return the quad of the frame being shown
+func (animation *Animation) GetQuad() *Quad {
+	return animation.frames[animation.frame].quad
+}
+
+// GetTexture will return the texture that the frames are quads of
+func (animation *Animation) GetTexture() ITexture {
+	return animation.texture
+}
+
+// GetDimensions will return the size of the frame being shown, before it was
+// trimmed if it was exported trimmed.
+func (animation *Animation) GetDimensions() (float32, float32) {
+	frame := animation.frames[animation.frame]
+	return frame.width, frame.height
+}
+
+// model will return the model matrix of the frame being shown with its trim
+// offset and flip applied.
+func (animation *Animation) model(args []float32) *mgl32.Mat4 {
+	frame := animation.frames[animation.frame]
+	local := mgl32.Translate3D(frame.x, frame.y, 0)
+	if animation.flipX {
+		local[0], local[12] = -1, frame.width-frame.x
+	}
+	if animation.flipY {
+		local[5], local[13] = -1, frame.height-frame.y
+	}
+	mat := generateModelMatFromArgs(args).Mul4(local)
+	return &mat
+}
+
+// Draw satisfies the Drawable interface. It draws the frame being shown. Inputs are
+// as follows
+// x, y, r, sx, sy, ox, oy, kx, ky
+// x, y are position
+// r is rotation
+// sx, sy is the scale, if sy is not given sy will equal sx
+// ox, oy are offset
+// kx, ky are the shear. If ky is not given ky will equal kx
+func (animation *Animation) Draw(args ...float32) {
+	quad := animation.frames[animation.frame].quad
+	batchDraw(gl.TRIANGLE_STRIP, animation.texture.getHandle(), animation.model(args), 4, quad.getVertices())
+}
+
+// AddTo will add the frame being shown to a sprite batch of the same texture with
+// the same arguments as draw.
+func (animation *Animation) AddTo(batch *SpriteBatch, args ...float32) error {
+	if err := animation.checkBatch(batch); err != nil {
+		return err
+	}
+	return batch.addv(animation.frames[animation.frame].quad.getVertices(), animation.model(args), -1)
+}
+
+// SetIn will change a sprite in a sprite batch to the frame being shown with the
+// same arguments as draw, so an animated sprite keeps its place in the batch.
+func (animation *Animation) SetIn(batch *SpriteBatch, index int, args ...float32) error {
+	if err := animation.checkBatch(batch); err != nil {
+		return err
+	} else if index < 0 {
+		return fmt.Errorf("invalid sprite index %v", index)
+	}
+	return batch.addv(animation.frames[animation.frame].quad.getVertices(), animation.model(args), index)
+}
+
+// checkBatch will return an error if the batch does not draw the texture of the
+// animation, since the quads of the frames would sample the wrong texture.
+func (animation *Animation) checkBatch(batch *SpriteBatch) error {
+	if batch.GetTexture() != animation.texture {
+		return fmt.Errorf("the sprite batch does not have the texture of the animation")
+	}
+	return nil
+}
@@ -0,0 +1,485 @@
+package gfx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/draw"
+	"image/png"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tanema/amore/file"
+)
+
+// defaultAtlasSize is the largest width and height of an atlas page if no size is given
+const defaultAtlasSize = 2048
+
+// AtlasOptions changes how the images of an atlas are packed
+type AtlasOptions struct {
+	Size    int32  // largest width and height of each page, 2048 if 0
+	Padding int32  // transparent pixels between packed images
+	Extrude int32  // pixels that the edges of each image are repeated outwards to stop bleeding when filtering
+	Mipmaps bool   // generate mipmaps for the pages
+	Cache   string // path, without an extension, to cache the packed pages at in the save directory
+}
+
+// Atlas is a collection of images packed into as few textures, called pages, as
+// possible so that they can be drawn with a single texture, like in a SpriteBatch.
+// Each image is a named Quad of the page it was packed into.
+type Atlas struct {
+	pages   []*Image
+	sprites map[string]atlasSprite
+}
+
+type atlasSprite struct {
+	quad *Quad
+	page int
+}
+
+// atlasSource is an image waiting to be packed
+type atlasSource struct {
+	name   string
+	pixels *image.RGBA
+	page   int
+	x, y   int
+}
+
+// atlasCache is the json written next to the cached pages
+type atlasCache struct {
+	Key     string                      `json:"key"`
+	Pages   []string                    `json:"pages"`
+	Sprites map[string]atlasCacheSprite `json:"sprites"`
+}
+
+type atlasCacheSprite struct {
+	Page int   `json:"page"`
+	X    int32 `json:"x"`
+	Y    int32 `json:"y"`
+	W    int32 `json:"w"`
+	H    int32 `json:"h"`
+}
+
+// NewAtlas will pack the images at the paths into an atlas. If a path is a
+// directory all the images in it and its sub directories are packed. Each image
+// is named by its path without the extension. If there is a cache path and the
+// cached atlas was packed from the same files with the same options it is loaded
+// instead of packing the images again.
+func NewAtlas(paths []string, options AtlasOptions) (*Atlas, error) {
+	if options.Size <= 0 {
+		options.Size = defaultAtlasSize
+	}
+	if maxTextureSize > 0 && options.Size > maxTextureSize {
+		options.Size = maxTextureSize
+	}
+	if options.Padding < 0 || options.Extrude < 0 {
+		return nil, fmt.Errorf("atlas padding and extrude cannot be negative")
+	}
+
+	files, err := atlasFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	key := atlasKey(files, options)
+	if options.Cache != "" {
+		if atlas, err := loadAtlasCache(options, key); err == nil {
+			return atlas, nil
+		}
+	}
+
+	sources := []*atlasSource{}
+	names := map[string]string{}
+	for _, filepath := range files {
+		name := strings.TrimSuffix(filepath, path.Ext(filepath))
+		if other, ok := names[name]; ok {
+			if other == filepath {
+				continue
+			}
+			return nil, fmt.Errorf("%v and %v would both be named %v in the atlas", other, filepath, name)
+		}
+		names[name] = filepath
+		pixels, err := decodeRGBA(filepath)
+		if err != nil {
+			return nil, fmt.Errorf("could not load %v for the atlas: %v", filepath, err)
+		}
+		sources = append(sources, &atlasSource{name: name, pixels: pixels})
+	}
+
+	pages, err := packAtlas(sources, options)
+	if err != nil {
+		return nil, err
+	}
+
+	atlas := &Atlas{sprites: map[string]atlasSprite{}}
+	for _, page := range pages {
+		atlas.pages = append(atlas.pages, newImageFromPixels(page, options.Mipmaps))
+	}
+	for _, source := range sources {
+		bounds, page := source.pixels.Bounds(), pages[source.page].Bounds()
+		atlas.sprites[source.name] = atlasSprite{
+			page: source.page,
+			quad: NewQuad(int32(source.x), int32(source.y), int32(bounds.Dx()), int32(bounds.Dy()), int32(page.Dx()), int32(page.Dy())),
+		}
+	}
+
+	if options.Cache != "" {
+		if err := atlas.writeCache(options.Cache, key, pages); err != nil {
+			return nil, fmt.Errorf("could not cache atlas: %v", err)
+		}
+	}
+	return atlas, nil
+}
+
+// GetQuad will return the quad of the named image in its page or nil if there is
+// no image with the name.
+func (atlas *Atlas) GetQuad(name string) *Quad {
+	sprite, ok := atlas.sprites[name]
+	if !ok {
+		return nil
+	}
+	return sprite.quad
+}
+
+// GetImage will return the page that the named image was packed into or nil if
+// there is no image with the name.
+func (atlas *Atlas) GetImage(name string) *Image {
+	sprite, ok := atlas.sprites[name]
+	if !ok {
+		return nil
+	}
+	return atlas.pages[sprite.page]
+}
+
+// GetNames will return the names of all the images in the atlas in order
+func (atlas *Atlas) GetNames() []string {
+	names := []string{}
+	for name := range atlas.sprites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetPages will return the textures that the images were packed into
+func (atlas *Atlas) GetPages() []*Image {
+	return atlas.pages
+}
+
+// Draw will draw the named image. Inputs are as follows
+// x, y, r, sx, sy, ox, oy, kx, ky
+// x, y are position
+// r is rotation
+// sx, sy is the scale, if sy is not given sy will equal sx
+// ox, oy are offset
+// kx, ky are the shear. If ky is not given ky will equal kx
+func (atlas *Atlas) Draw(name string, args ...float32) error {
+	sprite, ok := atlas.sprites[name]
+	if !ok {
+		return fmt.Errorf("no image named %v in the atlas", name)
+	}
+	atlas.pages[sprite.page].Drawq(sprite.quad, args...)
+	return nil
+}
+
+// atlasFiles will return the image files of the paths with the images in any
+// directories listed in place.
+func atlasFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, filepath := range paths {
+		info, err := file.GetInfo(filepath)
+		if err != nil {
+			return nil, err
+		} else if !info.IsDir {
+			files = append(files, filepath)
+			continue
+		}
+		names, err := file.List(filepath)
+		if err != nil {
+			return nil, err
+		}
+		children := []string{}
+		for _, name := range names {
+			child := path.Join(filepath, name)
+			if info, err := file.GetInfo(child); err == nil && info.IsDir {
+				children = append(children, child)
+				continue
+			}
+			switch strings.ToLower(path.Ext(name)) {
+			case ".png", ".jpg", ".jpeg", ".gif":
+				children = append(children, child)
+			}
+		}
+		found, err := atlasFiles(children)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// atlasKey will hash the files, their sizes and modification times and the options
+// so that a cache is only used if nothing has changed since it was written.
+func atlasKey(files []string, options AtlasOptions) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%v %v %v %v\n", options.Size, options.Padding, options.Extrude, options.Mipmaps)
+	for _, filepath := range files {
+		info, _ := file.GetInfo(filepath)
+		fmt.Fprintf(hash, "%v %v %v\n", filepath, info.Size, info.ModTime.UnixNano())
+	}
+	return fmt.Sprintf("%x", hash.Sum64())
+}
+
+func decodeRGBA(filepath string) (*image.RGBA, error) {
+	imgFile, err := file.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer imgFile.Close()
+	decoded, _, err := image.Decode(imgFile)
+	if err != nil {
+		return nil, err
+	}
+	bounds := decoded.Bounds()
+	pixels := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(pixels, pixels.Bounds(), decoded, bounds.Min, draw.Src)
+	return pixels, nil
+}
+
+// packAtlas will place the sources into as few pages as possible, tallest first,
+// and return the pages with the sources drawn into them.
+func packAtlas(sources []*atlasSource, options AtlasOptions) ([]*image.RGBA, error) {
+	sorted := make([]*atlasSource, len(sources))
+	copy(sorted, sources)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].pixels.Bounds(), sorted[j].pixels.Bounds()
+		if a.Dy() != b.Dy() {
+			return a.Dy() > b.Dy()
+		}
+		return a.Dx() > b.Dx()
+	})
+
+	size, padding, extrude := int(options.Size), int(options.Padding), int(options.Extrude)
+	packers := []*skylinePacker{}
+	for _, source := range sorted {
+		bounds := source.pixels.Bounds()
+		// the padding after each cell can hang off the edge of the page
+		w, h := bounds.Dx()+extrude*2+padding, bounds.Dy()+extrude*2+padding
+		placed := false
+		for i, packer := range packers {
+			if x, y, ok := packer.insert(w, h); ok {
+				source.page, source.x, source.y, placed = i, x+extrude, y+extrude, true
+				break
+			}
+		}
+		if placed {
+			continue
+		}
+		packer := newSkylinePacker(size+padding, size+padding)
+		x, y, ok := packer.insert(w, h)
+		if !ok {
+			return nil, fmt.Errorf("%v is larger than the atlas size of %v", source.name, size)
+		}
+		packers = append(packers, packer)
+		source.page, source.x, source.y = len(packers)-1, x+extrude, y+extrude
+	}
+
+	pages := make([]*image.RGBA, len(packers))
+	for i, packer := range packers {
+		w, h := nextPowerOfTwo(packer.usedWidth-padding), nextPowerOfTwo(packer.usedHeight-padding)
+		if w > size {
+			w = size
+		}
+		if h > size {
+			h = size
+		}
+		pages[i] = image.NewRGBA(image.Rect(0, 0, w, h))
+	}
+	for _, source := range sources {
+		drawExtruded(pages[source.page], source.pixels, source.x, source.y, extrude)
+	}
+	return pages, nil
+}
+
+// drawExtruded will draw the pixels into the page at x, y and repeat the edge
+// pixels outwards by extrude pixels.
+func drawExtruded(page, pixels *image.RGBA, x, y, extrude int) {
+	bounds := pixels.Bounds()
+	for py := -extrude; py < bounds.Dy()+extrude; py++ {
+		sy := clampInt(py, 0, bounds.Dy()-1)
+		for px := -extrude; px < bounds.Dx()+extrude; px++ {
+			sx := clampInt(px, 0, bounds.Dx()-1)
+			page.SetRGBA(x+px, y+py, pixels.RGBAAt(sx, sy))
+		}
+	}
+}
+
+// loadAtlasCache will load the cached atlas if it was packed with the same key
+func loadAtlasCache(options AtlasOptions, key string) (*Atlas, error) {
+	data, err := file.Read(options.Cache + ".json")
+	if err != nil {
+		return nil, err
+	}
+	var cache atlasCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, err
+	} else if cache.Key != key {
+		return nil, fmt.Errorf("atlas cache is out of date")
+	}
+
+	atlas := &Atlas{sprites: map[string]atlasSprite{}}
+	sizes := []image.Rectangle{}
+	for _, page := range cache.Pages {
+		pixels, err := decodeRGBA(path.Join(path.Dir(options.Cache), page))
+		if err != nil {
+			return nil, err
+		}
+		atlas.pages = append(atlas.pages, newImageFromPixels(pixels, options.Mipmaps))
+		sizes = append(sizes, pixels.Bounds())
+	}
+	for name, sprite := range cache.Sprites {
+		if sprite.Page < 0 || sprite.Page >= len(sizes) {
+			return nil, fmt.Errorf("atlas cache has an invalid page for %v", name)
+		}
+		page := sizes[sprite.Page]
+		atlas.sprites[name] = atlasSprite{
+			page: sprite.Page,
+			quad: NewQuad(sprite.X, sprite.Y, sprite.W, sprite.H, int32(page.Dx()), int32(page.Dy())),
+		}
+	}
+	return atlas, nil
+}
+
+// writeCache will write each page as a png next to a json file of the images in
+// them and the key they were packed with.
+func (atlas *Atlas) writeCache(cachePath, key string, pages []*image.RGBA) error {
+	cache := atlasCache{Key: key, Sprites: map[string]atlasCacheSprite{}}
+	for i, page := range pages {
+		name := fmt.Sprintf("%v_%v.png", path.Base(cachePath), i)
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, page); err != nil {
+			return err
+		}
+		if err := file.Write(path.Join(path.Dir(cachePath), name), buf.Bytes()); err != nil {
+			return err
+		}
+		cache.Pages = append(cache.Pages, name)
+	}
+	for name, sprite := range atlas.sprites {
+		x, y, w, h := sprite.quad.GetViewport()
+		cache.Sprites[name] = atlasCacheSprite{Page: sprite.page, X: x, Y: y, W: w, H: h}
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return file.Write(cachePath+".json", data)
+}
+
+// skylinePacker packs rectangles by keeping the top edge, the skyline, of what has
+// been packed so far and placing each rectangle where it keeps the skyline lowest.
+type skylinePacker struct {
+	width, height         int
+	usedWidth, usedHeight int
+	nodes                 []skylineNode
+}
+
+type skylineNode struct {
+	x, y, w int
+}
+
+func newSkylinePacker(width, height int) *skylinePacker {
+	return &skylinePacker{width: width, height: height, nodes: []skylineNode{{0, 0, width}}}
+}
+
+// insert will find a place for a w x h rectangle and return its top left corner or
+// false if it does not fit.
+func (packer *skylinePacker) insert(w, h int) (int, int, bool) {
+	best, bestY, bestWidth := -1, 0, 0
+	for i := range packer.nodes {
+		y, ok := packer.fit(i, w, h)
+		if ok && (best < 0 || y < bestY || (y == bestY && packer.nodes[i].w < bestWidth)) {
+			best, bestY, bestWidth = i, y, packer.nodes[i].w
+		}
+	}
+	if best < 0 {
+		return 0, 0, false
+	}
+
+	x := packer.nodes[best].x
+	node := skylineNode{x: x, y: bestY + h, w: w}
+	packer.nodes = append(packer.nodes[:best], append([]skylineNode{node}, packer.nodes[best:]...)...)
+
+	// shrink or remove the nodes that are now under the new node
+	for i := best + 1; i < len(packer.nodes); i++ {
+		prev := packer.nodes[i-1]
+		overlap := prev.x + prev.w - packer.nodes[i].x
+		if overlap <= 0 {
+			break
+		}
+		packer.nodes[i].x += overlap
+		packer.nodes[i].w -= overlap
+		if packer.nodes[i].w > 0 {
+			break
+		}
+		packer.nodes = append(packer.nodes[:i], packer.nodes[i+1:]...)
+		i--
+	}
+
+	// merge neighbours that are at the same height
+	for i := 0; i+1 < len(packer.nodes); i++ {
+		if packer.nodes[i].y == packer.nodes[i+1].y {
+			packer.nodes[i].w += packer.nodes[i+1].w
+			packer.nodes = append(packer.nodes[:i+1], packer.nodes[i+2:]...)
+			i--
+		}
+	}
+
+	if x+w > packer.usedWidth {
+		packer.usedWidth = x + w
+	}
+	if bestY+h > packer.usedHeight {
+		packer.usedHeight = bestY + h
+	}
+	return x, bestY, true
+}
+
+// fit will return the y a w x h rectangle would be placed at if its left edge is
+// at the node at index, or false if it would not fit.
+func (packer *skylinePacker) fit(index, w, h int) (int, bool) {
+	x := packer.nodes[index].x
+	if x+w > packer.width {
+		return 0, false
+	}
+	y := 0
+	for i, remaining := index, w; remaining > 0 && i < len(packer.nodes); i++ {
+		if packer.nodes[i].y > y {
+			y = packer.nodes[i].y
+		}
+		remaining -= packer.nodes[i].w
+	}
+	if y+h > packer.height {
+		return 0, false
+	}
+	return y, true
+}
+
+func nextPowerOfTwo(n int) int {
+	power := 1
+	for power < n {
+		power *= 2
+	}
+	return power
+}
+
+func clampInt(n, min, max int) int {
+	if n < min {
+		return min
+	} else if n > max {
+		return max
+	}
+	return n
+}
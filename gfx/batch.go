@@ -0,0 +1,137 @@
+package gfx
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/goxjs/gl"
+)
+
+// batchStride is the floats per vertex in the batch, x, y, u, v, r, g, b, a
+const batchStride = 8
+
+// maxBatchVertices is how many vertices the batch will hold before it is flushed
+// so that its buffer does not grow without bounds.
+const maxBatchVertices = 1 << 16
+
+// streamBatch collects the vertices of consecutive immediate draws, like
+// primitives, images and text, that use the same texture so that they are drawn
+// with a single draw call. Vertices are transformed and colored on the cpu as
+// they are added. The batch is flushed before anything changes the gl state that
+// it would be drawn with, like the shader, blend mode, stencil, scissor or canvas,
+// before any draw that does not batch, and when the frame is presented.
+// Batched draws send only the projection as the TransformMat of a shader.
+type streamBatch struct {
+	vertices []float32
+	texture  gl.Texture
+	mode     gl.Enum // gl.TRIANGLES or gl.POINTS
+	buffer   *vertexBuffer
+}
+
+var stream streamBatch
+
+// batchDraw will add the vertices of an immediate draw to the stream. The vertices
+// are drawn with the mode like gl.DrawArrays and each vertex is x, y or x, y, u, v
+// or x, y, u, v, r, g, b, a as given by the stride.
+func batchDraw(mode gl.Enum, texture gl.Texture, model *mgl32.Mat4, stride int, vertices []float32) {
+	order := triangleOrder(mode, len(vertices)/stride)
+	primitive := gl.Enum(gl.TRIANGLES)
+	if mode == gl.POINTS {
+		primitive = gl.POINTS
+	}
+	if len(stream.vertices) > 0 && (stream.texture != texture || stream.mode != primitive ||
+		len(stream.vertices)/batchStride+len(order) > maxBatchVertices) {
+		flushBatch()
+	}
+	stream.texture, stream.mode = texture, primitive
+
+	if model == nil {
+		model = &modelIdent
+	}
+	mat := glState.viewStack.Peek().Mul4(*model)
+	color := states.back().color
+	for _, i := range order {
+		vertex := vertices[i*stride : (i+1)*stride]
+		x, y := vertex[0], vertex[1]
+		var u, v float32
+		if stride >= 4 {
+			u, v = vertex[2], vertex[3]
+		}
+		r, g, b, a := color[0], color[1], color[2], color[3]
+		if stride >= 8 {
+			r, g, b, a = r*vertex[4], g*vertex[5], b*vertex[6], a*vertex[7]
+		}
+		stream.vertices = append(stream.vertices,
+			mat[0]*x+mat[4]*y+mat[12], mat[1]*x+mat[5]*y+mat[13], u, v, r, g, b, a)
+	}
+}
+
+// triangleOrder will return the order of count vertices drawn with mode as
+// separate triangles so that draws with different modes can share the stream.
+func triangleOrder(mode gl.Enum, count int) []int {
+	order := []int{}
+	switch mode {
+	case gl.TRIANGLE_FAN:
+		for i := 1; i+1 < count; i++ {
+			order = append(order, 0, i, i+1)
+		}
+	case gl.TRIANGLE_STRIP:
+		for i := 0; i+2 < count; i++ {
+			order = append(order, i, i+1, i+2)
+		}
+	default:
+		for i := 0; i < count; i++ {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// quadsToTriangles will convert vertices of quads laid out like quadIndices into
+// separate triangles.
+func quadsToTriangles(vertices []float32, stride int) []float32 {
+	triangles := make([]float32, 0, len(vertices)/4*6)
+	for quad := 0; quad+4*stride <= len(vertices); quad += 4 * stride {
+		for _, i := range []int{0, 1, 2, 2, 1, 3} {
+			triangles = append(triangles, vertices[quad+i*stride:quad+(i+1)*stride]...)
+		}
+	}
+	return triangles
+}
+
+// flushBatch will draw everything in the stream. It is called before any change to
+// the gl state that the batch would be drawn with.
+func flushBatch() {
+	if len(stream.vertices) == 0 {
+		return
+	}
+	// clear the batch first so that nothing below flushes it again
+	vertices := stream.vertices
+	stream.vertices = stream.vertices[:0]
+
+	sendDrawUniforms(glState.projectionStack.Peek())
+	bindTexture(stream.texture)
+	useVertexAttribArrays(shaderPos, shaderTexCoord, shaderColor)
+	// the color of each draw is already part of its vertices
+	gl.VertexAttrib4f(shaderConstantColor, 1, 1, 1, 1)
+
+	if stream.buffer == nil || len(stream.buffer.data) < len(vertices) {
+		size := batchStride * 1024
+		for size < len(vertices) {
+			size *= 2
+		}
+		stream.buffer = newVertexBuffer(size, vertices, UsageDynamic)
+	} else {
+		stream.buffer.fill(0, vertices)
+	}
+	stream.buffer.bind()
+	defer stream.buffer.unbind()
+
+	gl.VertexAttribPointer(shaderPos, 2, gl.FLOAT, false, batchStride*4, 0)
+	gl.VertexAttribPointer(shaderTexCoord, 2, gl.FLOAT, false, batchStride*4, 2*4)
+	gl.VertexAttribPointer(shaderColor, 4, gl.FLOAT, false, batchStride*4, 4*4)
+	gl.DrawArrays(stream.mode, 0, len(vertices)/batchStride)
+	countDraw(len(vertices) / batchStride)
+
+	color := states.back().color
+	gl.VertexAttrib4f(shaderConstantColor, color[0], color[1], color[2], color[3])
+}
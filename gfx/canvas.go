@@ -77,6 +77,7 @@ func (canvas *Canvas) startGrab() error {
 	if glState.currentCanvas == canvas {
 		return nil // already grabbing
 	}
+	flushBatch()
 
 	// cleanup after previous Canvas
 	if glState.currentCanvas != nil {
@@ -88,6 +89,7 @@ func (canvas *Canvas) startGrab() error {
 
 	// indicate we are using this Canvas.
 	glState.currentCanvas = canvas
+	frameStats.CanvasSwitches++
 	// bind the framebuffer object.
 	gl.BindFramebuffer(gl.FRAMEBUFFER, canvas.fbo)
 	SetViewport(0, 0, canvas.width, canvas.height)
@@ -105,36 +107,44 @@ func (canvas *Canvas) stopGrab(switchingToOtherCanvas bool) error {
 	if glState.currentCanvas != canvas {
 		return nil
 	}
+	flushBatch()
 	glState.projectionStack.Pop()
 	if !switchingToOtherCanvas {
 		// bind system framebuffer.
 		glState.currentCanvas = nil
+		frameStats.CanvasSwitches++
 		gl.BindFramebuffer(gl.FRAMEBUFFER, getDefaultFBO())
 		SetViewport(canvas.systemViewport[0], canvas.systemViewport[1], canvas.systemViewport[2], canvas.systemViewport[3])
 	}
 	return nil
 }
 
-// NewImageData will create an image from the canvas data. It will return an error
-// only if the dimensions given are invalid
+// NewImageData will create an image from the canvas data. The image is the right
+// way up, so drawing it looks the same as drawing the canvas. It will return an
+// error only if the dimensions given are invalid
 func (canvas *Canvas) NewImageData(x, y, w, h int32) (*Image, error) {
+	pixels, err := canvas.GetPixels(x, y, w, h)
+	if err != nil {
+		return nil, err
+	}
+	newImage := &Image{Texture: newImageTexture(pixels, false)}
+	registerVolatile(newImage)
+	return newImage, nil
+}
+
+// GetPixels will read the pixels in the rectangle of the canvas. It will return an
+// error only if the dimensions given are invalid
+func (canvas *Canvas) GetPixels(x, y, w, h int32) (*image.RGBA, error) {
 	if x < 0 || y < 0 || w <= 0 || h <= 0 || (x+w) > canvas.width || (y+h) > canvas.height {
 		return nil, fmt.Errorf("invalid ImageData rectangle dimensions")
 	}
 	prevCanvas := GetCanvas()
 	SetCanvas(canvas)
-	screenshot := image.NewRGBA(image.Rect(int(x), int(y), int(w), int(h)))
-	stride := int32(screenshot.Stride)
-	pixels := make([]byte, len(screenshot.Pix))
-	gl.ReadPixels(pixels, int(x), int(y), int(w), int(h), gl.RGBA, gl.UNSIGNED_BYTE)
-	for y := int32(0); y < h; y++ {
-		i := (h - 1 - y) * stride
-		copy(screenshot.Pix[y*stride:], pixels[i:i+w*4])
-	}
+	// Canvases are drawn upside down so the rows are already in image order
+	pixels := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	gl.ReadPixels(pixels.Pix, int(x), int(y), int(w), int(h), gl.RGBA, gl.UNSIGNED_BYTE)
 	SetCanvas(prevCanvas)
-	newImage := &Image{Texture: newImageTexture(screenshot, false)}
-	registerVolatile(newImage)
-	return newImage, nil
+	return pixels, nil
 }
 
 // checkCreateStencil if a stencil is set on a canvas then we need to create
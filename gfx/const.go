@@ -15,6 +15,10 @@ type (
 	CompareMode uint32
 	// Usage is used for sprite batch usage, and specifies if it is static, dynamic, or stream
 	Usage uint32
+	// MeshDrawMode is how the vertices of a mesh are drawn
+	MeshDrawMode uint32
+	// AnimationMode is how an animation continues after its last frame
+	AnimationMode int
 )
 
 // ColorMask contains an rgba color mask
@@ -27,6 +31,12 @@ var ( //opengl attribute variables
 	shaderTexCoord      = gl.Attrib{Value: 1}
 	shaderColor         = gl.Attrib{Value: 2}
 	shaderConstantColor = gl.Attrib{Value: 3}
+
+	// per instance attributes, only bound in the instanced variant of a shader
+	shaderInstanceTransform = gl.Attrib{Value: 4}
+	shaderInstanceOffset    = gl.Attrib{Value: 5}
+	shaderInstanceColor     = gl.Attrib{Value: 6}
+	shaderInstanceQuad      = gl.Attrib{Value: 7}
 )
 
 //texture wrap
@@ -70,3 +80,18 @@ const (
 	UsageStatic  Usage = 0x88E4
 	UsageDynamic Usage = 0x88E8
 )
+
+// mesh draw modes
+const (
+	MeshPoints    MeshDrawMode = 0x0000
+	MeshTriangles MeshDrawMode = 0x0004
+	MeshStrip     MeshDrawMode = 0x0005
+	MeshFan       MeshDrawMode = 0x0006
+)
+
+// animation modes
+const (
+	AnimationLoop AnimationMode = iota
+	AnimationPingPong
+	AnimationOnce
+)
@@ -28,6 +28,7 @@ type displayState struct {
 // glState keeps track of the context attributes
 type openglState struct {
 	initialized            bool
+	headless               bool
 	boundTextures          []gl.Texture
 	curTextureUnit         int
 	viewport               []int32
@@ -40,6 +41,8 @@ type openglState struct {
 	currentShader          *Shader
 	textureCounters        []int
 	writingToStencil       bool
+	instancing             bool
+	instancingSupported    bool
 }
 
 // newDisplayState initializes a display states default values
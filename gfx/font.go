@@ -8,15 +8,21 @@ import (
 type Font struct {
 	rasterizers []*rasterizer
 	lineHeight  float32
+	runeSets    [][]rune
+	loadFace    func() (font.Face, error) // used to load the face again when reloading
 }
 
 // NewFont rasterizes a ttf font and returns a pointer to a new Font
 func NewFont(filename string, fontSize float32) (*Font, error) {
-	face, err := font.NewTTFFace(filename, fontSize)
+	loadFace := func() (font.Face, error) { return font.NewTTFFace(filename, fontSize) }
+	face, err := loadFace()
 	if err != nil {
 		return nil, err
 	}
-	return newFont(face, font.ASCII, font.Latin), nil
+	ttfFont := newFont(face, font.ASCII, font.Latin)
+	ttfFont.loadFace = loadFace
+	trackFile(filename, ttfFont)
+	return ttfFont, nil
 }
 
 // NewImageFont rasterizes an image using the glyphHints. The glyphHints should
@@ -24,18 +30,22 @@ func NewFont(filename string, fontSize float32) (*Font, error) {
 // and height. Using the glyphHints, the image is split up into equal rectangles
 // for rasterization. The function will return a pointer to a new Font
 func NewImageFont(filename, glyphHints string) (*Font, error) {
-	face, err := font.NewBitmapFace(filename, glyphHints)
+	loadFace := func() (font.Face, error) { return font.NewBitmapFace(filename, glyphHints) }
+	face, err := loadFace()
 	if err != nil {
 		return nil, err
 	}
-	return newFont(face, []rune(glyphHints)), nil
+	imageFont := newFont(face, []rune(glyphHints))
+	imageFont.loadFace = loadFace
+	trackFile(filename, imageFont)
+	return imageFont, nil
 }
 
 func newFont(face font.Face, runeSets ...[]rune) *Font {
 	if runeSets == nil || len(runeSets) == 0 {
 		runeSets = append(runeSets, font.ASCII, font.Latin)
 	}
-	return &Font{rasterizers: []*rasterizer{newRasterizer(face, runeSets...)}}
+	return &Font{rasterizers: []*rasterizer{newRasterizer(face, runeSets...)}, runeSets: runeSets}
 }
 
 // SetLineHeight sets the height between lines
@@ -0,0 +1,156 @@
+package gfx
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goxjs/gl"
+)
+
+// RenderImage will call draw with a new canvas of w x h bound and return the pixels
+// that were drawn. The canvas is cleared to transparent black and the transform is
+// reset before draw is called, and any state that draw changes is restored
+// afterwards so that renders do not affect each other. Renders are not counted in
+// the stats of the frame.
+func RenderImage(w, h int32, draw func()) (*image.RGBA, error) {
+	if !glState.initialized {
+		return nil, fmt.Errorf("rendering an image needs a gl context")
+	}
+	canvas := NewCanvas(w, h)
+	if canvas.status != gl.FRAMEBUFFER_COMPLETE {
+		return nil, fmt.Errorf("could not create a %vx%v canvas to render to", w, h)
+	}
+
+	var pixels *image.RGBA
+	var err error
+	IgnoreStats(func() {
+		instancing := glState.instancing
+		Push()
+		SetCanvas(canvas)
+		Origin()
+		Clear(0, 0, 0, 0)
+		draw()
+		pixels, err = canvas.GetPixels(0, 0, w, h)
+		Pop()
+		restoreState()
+		glState.instancing = instancing
+	})
+	return pixels, err
+}
+
+// restoreState will set the gl state back to the current display state after a pop
+func restoreState() {
+	state := states.back()
+	SetCanvas(state.canvas)
+	SetColor(state.color[0], state.color[1], state.color[2], state.color[3])
+	SetBlendMode(state.blendMode)
+	SetColorMask(state.colorMask.r, state.colorMask.g, state.colorMask.b, state.colorMask.a)
+	SetShader(state.shader)
+	SetStencilTest(state.stencilCompare, state.stencilTestValue)
+	if state.scissor {
+		SetScissor(state.scissorBox[0], state.scissorBox[1], state.scissorBox[2], state.scissorBox[3])
+	} else {
+		ClearScissor()
+	}
+}
+
+// DiffImages will compare two images pixel by pixel and return how many pixels
+// differ. A pixel differs if any of its channels differ by more than the tolerance,
+// which is between 0 and 1 like colors. The diff image has differing pixels
+// in red over a faded copy of the expected image.
+func DiffImages(actual, expected image.Image, tolerance float32) (int, *image.RGBA, error) {
+	bounds := expected.Bounds()
+	if actual.Bounds().Dx() != bounds.Dx() || actual.Bounds().Dy() != bounds.Dy() {
+		return 0, nil, fmt.Errorf("expected an image of %vx%v but it was %vx%v",
+			bounds.Dx(), bounds.Dy(), actual.Bounds().Dx(), actual.Bounds().Dy())
+	}
+
+	maxDelta := int(tolerance * 255)
+	offset := actual.Bounds().Min.Sub(bounds.Min)
+	diff := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	count := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			want := color.NRGBAModel.Convert(expected.At(x, y)).(color.NRGBA)
+			got := color.NRGBAModel.Convert(actual.At(x+offset.X, y+offset.Y)).(color.NRGBA)
+			dx, dy := x-bounds.Min.X, y-bounds.Min.Y
+			if channelDelta(want.R, got.R) > maxDelta || channelDelta(want.G, got.G) > maxDelta ||
+				channelDelta(want.B, got.B) > maxDelta || channelDelta(want.A, got.A) > maxDelta {
+				count++
+				diff.Set(dx, dy, color.RGBA{255, 0, 0, 255})
+			} else {
+				gray := uint8((int(want.R) + int(want.G) + int(want.B)) / 3 * int(want.A) / 255 / 4)
+				diff.Set(dx, dy, color.RGBA{gray, gray, gray, 255})
+			}
+		}
+	}
+	return count, diff, nil
+}
+
+func channelDelta(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// CompareGolden will compare the image to the golden png image at path. If update
+// is true the image is written as the golden image instead, a missing golden
+// image is an error otherwise so that a test cannot pass without one. Up to
+// maxDiff pixels may differ by more than the tolerance, which allows for small
+// rasterization differences like anti-aliasing between gl drivers. When more
+// differ the image and a diff are written next to the golden image as
+// name.actual.png and name.diff.png so that they can be looked at.
+func CompareGolden(img image.Image, path string, tolerance float32, maxDiff int, update bool) error {
+	if update {
+		return writePNG(path, img)
+	}
+	goldenFile, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no golden image %v, write it with --update", path)
+	} else if err != nil {
+		return err
+	}
+	golden, err := png.Decode(goldenFile)
+	goldenFile.Close()
+	if err != nil {
+		return fmt.Errorf("could not decode golden image %v: %v", path, err)
+	}
+
+	count, diff, err := DiffImages(img, golden, tolerance)
+	if err != nil {
+		return fmt.Errorf("%v: %v", path, err)
+	} else if count <= maxDiff {
+		return nil
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	if err := writePNG(base+".actual.png", img); err != nil {
+		return err
+	}
+	if err := writePNG(base+".diff.png", diff); err != nil {
+		return err
+	}
+	return fmt.Errorf("%v pixels differ from %v by more than %v, %v are allowed, see %v.actual.png and %v.diff.png",
+		count, path, tolerance, maxDiff, base, base)
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(out, img); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -46,7 +46,6 @@ func Arc(mode string, x, y, radius, angle1, angle2 float32, points int) {
 		return
 	}
 
-	phi := angle1
 	numCoords := (points + 3) * 2
 	coords := make([]float32, numCoords)
 	coords[0] = x
@@ -55,7 +54,7 @@ func Arc(mode string, x, y, radius, angle1, angle2 float32, points int) {
 	coords[numCoords-1] = y
 
 	for i := 0; i <= points; i++ {
-		phi = phi + angleShift
+		phi := angle1 + float32(i)*angleShift
 		coords[2*(i+1)] = x + radius*float32(math.Cos(float64(phi)))
 		coords[2*(i+1)+1] = y + radius*float32(math.Sin(float64(phi)))
 	}
@@ -63,16 +62,7 @@ func Arc(mode string, x, y, radius, angle1, angle2 float32, points int) {
 	if mode == "line" {
 		PolyLine(coords)
 	} else {
-		prepareDraw(nil)
-		bindTexture(glState.defaultTexture)
-		useVertexAttribArrays(shaderPos)
-
-		buffer := newVertexBuffer(len(coords), coords, UsageStatic)
-		buffer.bind()
-		defer buffer.unbind()
-
-		gl.VertexAttribPointer(shaderPos, 2, gl.FLOAT, false, 0, 0)
-		gl.DrawArrays(gl.TRIANGLE_FAN, 0, len(coords)/2-1)
+		batchDraw(gl.TRIANGLE_FAN, glState.defaultTexture, nil, 2, coords[:len(coords)-2])
 	}
 }
 
@@ -103,32 +93,14 @@ func Ellipse(mode string, x, y, radiusx, radiusy float32, points int) {
 	if mode == "line" {
 		PolyLine(coords)
 	} else {
-		prepareDraw(nil)
-		bindTexture(glState.defaultTexture)
-		useVertexAttribArrays(shaderPos)
-
-		buffer := newVertexBuffer(len(coords), coords, UsageStatic)
-		buffer.bind()
-		defer buffer.unbind()
-
-		gl.VertexAttribPointer(shaderPos, 2, gl.FLOAT, false, 0, 0)
-		gl.DrawArrays(gl.TRIANGLE_FAN, 0, len(coords)/2-1)
+		batchDraw(gl.TRIANGLE_FAN, glState.defaultTexture, nil, 2, coords[:len(coords)-2])
 	}
 }
 
 // Points will draw a point on the screen at x, y position. The size of the point
 // is dependant on the point size set with SetPointSize.
 func Points(coords []float32) {
-	prepareDraw(nil)
-	bindTexture(glState.defaultTexture)
-	useVertexAttribArrays(shaderPos)
-
-	buffer := newVertexBuffer(len(coords), coords, UsageStatic)
-	buffer.bind()
-	defer buffer.unbind()
-
-	gl.VertexAttribPointer(shaderPos, 2, gl.FLOAT, false, 0, 0)
-	gl.DrawArrays(gl.POINTS, 0, len(coords)/2)
+	batchDraw(gl.POINTS, glState.defaultTexture, nil, 2, coords)
 }
 
 // PolyLine will draw a line with an array in the form of x1, y1, x2, y2, x3, y3, ..... xn, yn
@@ -151,16 +123,7 @@ func Polygon(mode string, coords []float32) {
 	if mode == "line" {
 		PolyLine(coords)
 	} else {
-		prepareDraw(nil)
-		bindTexture(glState.defaultTexture)
-		useVertexAttribArrays(shaderPos)
-
-		buffer := newVertexBuffer(len(coords), coords, UsageStatic)
-		buffer.bind()
-		defer buffer.unbind()
-
-		gl.VertexAttribPointer(shaderPos, 2, gl.FLOAT, false, 0, 0)
-		gl.DrawArrays(gl.TRIANGLE_FAN, 0, len(coords)/2-1)
+		batchDraw(gl.TRIANGLE_FAN, glState.defaultTexture, nil, 2, coords[:len(coords)-2])
 	}
 }
 
@@ -169,6 +132,7 @@ func NewScreenshot() *Image {
 	// Temporarily unbind the currently active canvas (glReadPixels reads the active framebuffer, not the main one.)
 	canvas := GetCanvas()
 	SetCanvas(nil)
+	flushBatch()
 
 	w, h := int32(screenWidth), int32(screenHeight)
 	screenshot := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
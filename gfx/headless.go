@@ -0,0 +1,86 @@
+package gfx
+
+import (
+	"image"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/go-gl/mathgl/mgl32/matstack"
+
+	"github.com/tanema/amore/file"
+)
+
+// headlessLoader is implemented by volatiles that can load the parts that do not
+// need a gl context, like the size of an image, when running headless.
+type headlessLoader interface {
+	loadHeadless()
+}
+
+// InitHeadless will set up the graphics state without a gl context so that a
+// program can run without a window. Images, canvases, fonts and text can still be
+// created and measured but nothing is uploaded and drawing does nothing.
+func InitHeadless(width, height int32) {
+	if glState.initialized || glState.headless {
+		return
+	}
+	glState.headless = true
+	glState.projectionStack = matstack.NewMatStack()
+	glState.viewStack = matstack.NewMatStack()
+	screenWidth, screenHeight = width, height
+	glState.viewport = []int32{0, 0, width, height}
+	glState.projectionStack.Load(mgl32.Ortho(0, float32(width), float32(height), 0, -1, 1))
+	SetBackgroundColor(0, 0, 0, 1)
+	for _, vol := range allVolatile {
+		loadHeadless(vol)
+	}
+	allVolatile = []volatile{}
+}
+
+// IsHeadless will return true if graphics were initialized without a gl context
+func IsHeadless() bool {
+	return glState.headless
+}
+
+func loadHeadless(vol volatile) {
+	if loader, ok := vol.(headlessLoader); ok {
+		loader.loadHeadless()
+	}
+}
+
+// newHeadlessTexture will create a texture that has a size but no gl texture
+func newHeadlessTexture(width, height int32) *Texture {
+	texture := &Texture{
+		Width:  width,
+		Height: height,
+		wrap:   Wrap{s: WrapClamp, t: WrapClamp},
+		filter: newFilter(),
+	}
+	texture.generateVerticies()
+	return texture
+}
+
+// loadHeadless will only decode the size of the image
+func (img *Image) loadHeadless() {
+	if img.pixels != nil {
+		bounds := img.pixels.Bounds()
+		img.Texture = newHeadlessTexture(int32(bounds.Dx()), int32(bounds.Dy()))
+		return
+	}
+	imgFile, err := file.Open(img.filePath)
+	if err != nil {
+		return
+	}
+	defer imgFile.Close()
+	if config, _, err := image.DecodeConfig(imgFile); err == nil {
+		img.Texture = newHeadlessTexture(int32(config.Width), int32(config.Height))
+	}
+}
+
+func (canvas *Canvas) loadHeadless() {
+	canvas.Texture = newHeadlessTexture(canvas.width, canvas.height)
+}
+
+// loadHeadless will lay out the text so that it can be measured. Laying out text
+// only fills vertex data so it does not need a context.
+func (text *Text) loadHeadless() {
+	text.loadVolatile()
+}
@@ -14,6 +14,7 @@ import (
 type Image struct {
 	*Texture
 	filePath string
+	pixels   image.Image // decoded pixels for images that do not come from a file
 	mipmaps  bool
 }
 
@@ -22,12 +23,23 @@ type Image struct {
 func NewImage(path string, mipmapped bool) *Image {
 	newImage := &Image{filePath: path, mipmaps: mipmapped}
 	registerVolatile(newImage)
+	trackFile(path, newImage)
+	return newImage
+}
+
+// newImageFromPixels will create an image from pixels that are already decoded
+func newImageFromPixels(pixels image.Image, mipmapped bool) *Image {
+	newImage := &Image{pixels: pixels, mipmaps: mipmapped}
+	registerVolatile(newImage)
 	return newImage
 }
 
 // loadVolatile will create the volatile objects
 func (img *Image) loadVolatile() bool {
-	if img.filePath == "" {
+	if img.pixels != nil {
+		img.Texture = newImageTexture(img.pixels, img.mipmaps)
+		return true
+	} else if img.filePath == "" {
 		return false
 	}
 
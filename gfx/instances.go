@@ -0,0 +1,265 @@
+package gfx
+
+import (
+	"fmt"
+
+	"github.com/go-gl/mathgl/mgl32"
+
+	"github.com/goxjs/gl"
+)
+
+// instanceStride is the floats per instance. Each instance is a 2d transform as
+// 4 values and an offset, a color and a quad rect in texture coordinates.
+const instanceStride = 4 + 2 + 4 + 4
+
+// instanceAttributes are where each per instance attribute is in an instance
+var instanceAttributes = []struct {
+	attrib     gl.Attrib
+	components int
+	offset     int
+}{
+	{shaderInstanceTransform, 4, 0},
+	{shaderInstanceOffset, 2, 4},
+	{shaderInstanceColor, 4, 6},
+	{shaderInstanceQuad, 4, 10},
+}
+
+// Instances is a buffer of transforms, colors and quads that can be attached to a
+// Mesh or SpriteBatch so that it is drawn once for every instance in a single draw
+// call. Moving thousands of particles only changes the instances and not the
+// vertices of what they draw. If the gpu does not support instancing the
+// instances are expanded on the cpu when they are drawn.
+type Instances struct {
+	size            int
+	count           int
+	color           []float32 // Current color. This color, if present, will be applied to the next added instance.
+	arrayBuf        *vertexBuffer
+	usage           Usage
+	dirty           bool
+	expanded        *vertexBuffer // vertices for drawing without instancing support
+	expandedIndices *quadIndices  // quad indices for drawing a sprite batch without instancing support
+}
+
+// vertexLayout is where the attributes that instances change are in a vertex as
+// offsets in floats. Missing attributes are -1.
+type vertexLayout struct {
+	stride          int
+	position        int
+	texCoord        int
+	color           int
+	colorComponents int
+}
+
+// NewInstances will create a buffer that can hold size instances
+func NewInstances(size int, usage Usage) *Instances {
+	return &Instances{
+		size:     size,
+		usage:    usage,
+		color:    []float32{1, 1, 1, 1},
+		arrayBuf: newVertexBuffer(size*instanceStride, []float32{}, usage),
+	}
+}
+
+// Add adds an instance that draws the whole mesh or batch.
+// x, y The position to draw the object
+// r rotation of the object
+// sx, sy scale of the object
+// ox, oy offset of the object
+// kx, ky shear of the object
+func (instances *Instances) Add(args ...float32) error {
+	return instances.setv(-1, generateModelMatFromArgs(args), nil)
+}
+
+// Addq adds an instance that draws a quad of the texture. The texture coordinates
+// of the mesh or batch are mapped into the quad and the vertices are scaled to
+// its size, so a sprite the size of its texture is drawn like Drawq.
+func (instances *Instances) Addq(quad *Quad, args ...float32) error {
+	return instances.setv(-1, generateModelMatFromArgs(args), quad)
+}
+
+// Set changes an instance with the same arguments as add
+func (instances *Instances) Set(index int, args ...float32) error {
+	return instances.setv(index, generateModelMatFromArgs(args), nil)
+}
+
+// Setq changes an instance with the same arguments as addq
+func (instances *Instances) Setq(index int, quad *Quad, args ...float32) error {
+	return instances.setv(index, generateModelMatFromArgs(args), quad)
+}
+
+// Clear will remove all the instances
+func (instances *Instances) Clear() {
+	instances.count = 0
+}
+
+// SetColor will set the color that will be used for the next add or set operations.
+func (instances *Instances) SetColor(vals ...float32) {
+	instances.color = vals
+}
+
+// ClearColor will reset the color back to white
+func (instances *Instances) ClearColor() {
+	instances.color = []float32{1, 1, 1, 1}
+}
+
+// GetColor will return the currently used color.
+func (instances *Instances) GetColor() []float32 {
+	return instances.color
+}
+
+// GetCount will return the amount of instances already added
+func (instances *Instances) GetCount() int {
+	return instances.count
+}
+
+// SetBufferSize will resize the buffer, change the limit of instances you can add.
+func (instances *Instances) SetBufferSize(newsize int) error {
+	if newsize <= 0 {
+		return fmt.Errorf("invalid Instances size")
+	} else if newsize == instances.size {
+		return nil
+	}
+	instances.arrayBuf = newVertexBuffer(newsize*instanceStride, instances.arrayBuf.data, instances.usage)
+	instances.size = newsize
+	if instances.count > newsize {
+		instances.count = newsize
+	}
+	return nil
+}
+
+// GetBufferSize will return the limit of instances you can add.
+func (instances *Instances) GetBufferSize() int {
+	return instances.size
+}
+
+// setv will set the instance at index, or add one if the index is -1. The data is
+// only uploaded when the instances are drawn so setting every instance each frame
+// is a single upload.
+func (instances *Instances) setv(index int, mat *mgl32.Mat4, quad *Quad) error {
+	if index == -1 {
+		if instances.count >= instances.size {
+			return fmt.Errorf("Instances Buffer Full")
+		}
+		index = instances.count
+		instances.count++
+	} else if index < 0 || index >= instances.count {
+		return fmt.Errorf("invalid instance index %v", index)
+	}
+
+	rect := []float32{0, 0, 1, 1}
+	if quad != nil {
+		rect = []float32{quad.x / quad.sw, quad.y / quad.sh, quad.w / quad.sw, quad.h / quad.sh}
+	}
+
+	instance := instances.arrayBuf.data[index*instanceStride : (index+1)*instanceStride]
+	copy(instance, []float32{mat[0], mat[1], mat[4], mat[5], mat[12], mat[13]})
+	copy(instance[6:], instances.color)
+	copy(instance[10:], rect)
+	instances.dirty = true
+	return nil
+}
+
+// flush will upload the instances if they have changed since the last draw
+func (instances *Instances) flush() {
+	if !instances.dirty || !instances.arrayBuf.vbo.Valid() {
+		return
+	}
+	instances.arrayBuf.modifiedOffset = 0
+	instances.arrayBuf.modifiedSize = instances.count * instanceStride
+	instances.arrayBuf.bufferData()
+	instances.dirty = false
+}
+
+// bind will point the per instance attributes at the instances so that they
+// advance once per instance.
+func (instances *Instances) bind() {
+	instances.flush()
+	instances.arrayBuf.bind()
+	for _, attrib := range instanceAttributes {
+		gl.EnableVertexAttribArray(attrib.attrib)
+		gl.VertexAttribPointer(attrib.attrib, attrib.components, gl.FLOAT, false, instanceStride*4, attrib.offset*4)
+		vertexAttribDivisor(attrib.attrib, 1)
+	}
+}
+
+// unbind will disable the per instance attributes after an instanced draw
+func (instances *Instances) unbind() {
+	for _, attrib := range instanceAttributes {
+		vertexAttribDivisor(attrib.attrib, 0)
+		gl.DisableVertexAttribArray(attrib.attrib)
+	}
+	instances.arrayBuf.unbind()
+}
+
+// useInstancedShader will switch to the instanced variant of the current shader
+// and prepare it for drawing with the model transform. The returned function
+// switches back to the shader.
+func useInstancedShader(model *mgl32.Mat4) func() {
+	shader := glState.currentShader
+	shader.instancedVariant().attach(false)
+	prepareDraw(model)
+	return func() { shader.attach(false) }
+}
+
+// drawArrays will draw count vertices from first once for every instance
+func (instances *Instances) drawArrays(mode gl.Enum, first, count int) {
+	drawArraysInstanced(mode, first, count, instances.count)
+	countDraw(count * instances.count)
+}
+
+// expand will return the vertices repeated for every instance with the instance
+// applied to them, for drawing without instancing support. If the vertices have no
+// color one is added to the end of each vertex. The stride of the expanded
+// vertices is returned with them.
+func (instances *Instances) expand(vertices []float32, layout vertexLayout) ([]float32, int) {
+	stride, color, colorComponents := layout.stride, layout.color, layout.colorComponents
+	if color < 0 {
+		stride, color, colorComponents = layout.stride+4, layout.stride, 4
+	}
+
+	vertexCount := len(vertices) / layout.stride
+	expanded := make([]float32, instances.count*vertexCount*stride)
+	for i := 0; i < instances.count; i++ {
+		instance := instances.arrayBuf.data[i*instanceStride : (i+1)*instanceStride]
+		for j := 0; j < vertexCount; j++ {
+			in := vertices[j*layout.stride : (j+1)*layout.stride]
+			out := expanded[(i*vertexCount+j)*stride : (i*vertexCount+j+1)*stride]
+			copy(out, in)
+			if layout.position >= 0 {
+				x, y := in[layout.position]*instance[12], in[layout.position+1]*instance[13]
+				out[layout.position] = instance[0]*x + instance[2]*y + instance[4]
+				out[layout.position+1] = instance[1]*x + instance[3]*y + instance[5]
+			}
+			if layout.texCoord >= 0 {
+				out[layout.texCoord] = instance[10] + in[layout.texCoord]*instance[12]
+				out[layout.texCoord+1] = instance[11] + in[layout.texCoord+1]*instance[13]
+			}
+			if layout.color < 0 {
+				copy(out[color:], []float32{1, 1, 1, 1})
+			}
+			for k := 0; k < colorComponents; k++ {
+				out[color+k] *= instance[6+k]
+			}
+		}
+	}
+	return expanded, stride
+}
+
+// expandedBuffer will return a buffer holding the expanded vertices. The buffer
+// is kept and only grows so that drawing every frame does not create buffers.
+func (instances *Instances) expandedBuffer(vertices []float32) *vertexBuffer {
+	if instances.expanded == nil || len(instances.expanded.data) < len(vertices) {
+		instances.expanded = newVertexBuffer(len(vertices), vertices, UsageStream)
+	} else {
+		instances.expanded.fill(0, vertices)
+	}
+	return instances.expanded
+}
+
+// expandedQuadIndices will return quad indices for at least size quads
+func (instances *Instances) expandedQuadIndices(size int) *quadIndices {
+	if instances.expandedIndices == nil || len(instances.expandedIndices.data) < size*6 {
+		instances.expandedIndices = newQuadIndices(size)
+	}
+	return instances.expandedIndices
+}
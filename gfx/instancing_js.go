@@ -0,0 +1,39 @@
+// +build js
+
+package gfx
+
+import (
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/goxjs/gl"
+)
+
+var (
+	webglContext    *js.Object // the current webgl context
+	instancedArrays *js.Object // the ANGLE_instanced_arrays extension if it is supported
+)
+
+// supportsInstancing will check if the context has the ANGLE_instanced_arrays
+// extension for drawing instances with vertex attribute divisors.
+func supportsInstancing() bool {
+	if webglContext == nil {
+		return false
+	}
+	instancedArrays = webglContext.Call("getExtension", "ANGLE_instanced_arrays")
+	return instancedArrays != nil
+}
+
+func setCurrentContext(context interface{}) {
+	webglContext, _ = context.(*js.Object)
+}
+
+func vertexAttribDivisor(attrib gl.Attrib, divisor int) {
+	instancedArrays.Call("vertexAttribDivisorANGLE", attrib.Value, divisor)
+}
+
+func drawArraysInstanced(mode gl.Enum, first, count, instances int) {
+	instancedArrays.Call("drawArraysInstancedANGLE", mode, first, count, instances)
+}
+
+func drawElementsInstanced(mode gl.Enum, count int, ty gl.Enum, offset, instances int) {
+	instancedArrays.Call("drawElementsInstancedANGLE", mode, count, ty, offset, instances)
+}
@@ -0,0 +1,32 @@
+// +build !js
+
+package gfx
+
+import (
+	"strings"
+
+	gogl "github.com/go-gl/gl/v2.1/gl"
+	"github.com/goxjs/gl"
+)
+
+// supportsInstancing will check if the context has the extensions for drawing
+// instances with vertex attribute divisors.
+func supportsInstancing() bool {
+	extensions := gl.GetString(gl.EXTENSIONS)
+	return strings.Contains(extensions, "GL_ARB_instanced_arrays") &&
+		strings.Contains(extensions, "GL_ARB_draw_instanced")
+}
+
+func setCurrentContext(context interface{}) {}
+
+func vertexAttribDivisor(attrib gl.Attrib, divisor int) {
+	gogl.VertexAttribDivisorARB(uint32(attrib.Value), uint32(divisor))
+}
+
+func drawArraysInstanced(mode gl.Enum, first, count, instances int) {
+	gogl.DrawArraysInstancedARB(uint32(mode), int32(first), int32(count), int32(instances))
+}
+
+func drawElementsInstanced(mode gl.Enum, count int, ty gl.Enum, offset, instances int) {
+	gogl.DrawElementsInstancedARB(uint32(mode), int32(count), uint32(ty), gogl.PtrOffset(offset), int32(instances))
+}
@@ -0,0 +1,372 @@
+package gfx
+
+import (
+	"fmt"
+
+	"github.com/goxjs/gl"
+)
+
+// VertexAttribute declares an attribute of each vertex in a mesh. The name is the
+// name of the attribute in shader code and it has 1 to 4 float components.
+type VertexAttribute struct {
+	Name       string
+	Components int
+}
+
+// Mesh is a collection of vertices with a user declared format that is drawn with
+// a single draw call. It can be used to draw any shape, like deformed sprites,
+// trails and terrain strips, that the other primitives cannot.
+type Mesh struct {
+	format      []VertexAttribute
+	stride      int // floats per vertex
+	vertexCount int
+	arrayBuf    *vertexBuffer
+	indices     *indexBuffer
+	vertexMap   []uint32
+	mode        MeshDrawMode
+	texture     ITexture
+	instances   *Instances
+	rangeMin    int
+	rangeMax    int
+}
+
+// builtinAttributes are the attributes bound to fixed locations in every shader
+var builtinAttributes = map[string]gl.Attrib{
+	"VertexPosition": shaderPos,
+	"VertexTexCoord": shaderTexCoord,
+	"VertexColor":    shaderColor,
+}
+
+// DefaultVertexFormat is the vertex format of a mesh if no format is given. Each
+// vertex is x, y, u, v, r, g, b, a.
+var DefaultVertexFormat = []VertexAttribute{
+	{Name: "VertexPosition", Components: 2},
+	{Name: "VertexTexCoord", Components: 2},
+	{Name: "VertexColor", Components: 4},
+}
+
+// NewMesh will create a mesh with the vertex format, the vertices given as a flat
+// array of the attribute values for each vertex, in the order of the format. If the
+// format is nil the DefaultVertexFormat is used. Attributes with names other than
+// VertexPosition, VertexTexCoord and VertexColor can be used in shader code by
+// declaring an attribute with the same name.
+func NewMesh(format []VertexAttribute, vertices []float32, mode MeshDrawMode, usage Usage) (*Mesh, error) {
+	if format == nil {
+		format = DefaultVertexFormat
+	}
+	stride := 0
+	names := map[string]bool{}
+	for _, attrib := range format {
+		if attrib.Components < 1 || attrib.Components > 4 {
+			return nil, fmt.Errorf("vertex attribute %v needs 1 to 4 components", attrib.Name)
+		} else if names[attrib.Name] {
+			return nil, fmt.Errorf("vertex attribute %v declared more than once", attrib.Name)
+		}
+		names[attrib.Name] = true
+		stride += attrib.Components
+	}
+	if stride == 0 {
+		return nil, fmt.Errorf("a vertex format needs at least one attribute")
+	} else if len(vertices) == 0 || len(vertices)%stride != 0 {
+		return nil, fmt.Errorf("vertices do not match the vertex format of %v values per vertex", stride)
+	}
+
+	return &Mesh{
+		format:      format,
+		stride:      stride,
+		vertexCount: len(vertices) / stride,
+		arrayBuf:    newVertexBuffer(len(vertices), vertices, usage),
+		mode:        mode,
+		rangeMin:    -1,
+		rangeMax:    -1,
+	}, nil
+}
+
+// GetVertexFormat will return the format of each vertex in the mesh
+func (mesh *Mesh) GetVertexFormat() []VertexAttribute {
+	return mesh.format
+}
+
+// GetVertexCount will return the number of vertices in the mesh
+func (mesh *Mesh) GetVertexCount() int {
+	return mesh.vertexCount
+}
+
+// SetVertex will set the attribute values of the vertex at index. If fewer values
+// than the format has are given only the first values are changed.
+func (mesh *Mesh) SetVertex(index int, values []float32) error {
+	if index < 0 || index >= mesh.vertexCount {
+		return fmt.Errorf("invalid vertex index %v", index)
+	} else if len(values) > mesh.stride {
+		values = values[:mesh.stride]
+	}
+	mesh.arrayBuf.fill(index*mesh.stride, values)
+	return nil
+}
+
+// GetVertex will return the attribute values of the vertex at index
+func (mesh *Mesh) GetVertex(index int) ([]float32, error) {
+	if index < 0 || index >= mesh.vertexCount {
+		return nil, fmt.Errorf("invalid vertex index %v", index)
+	}
+	values := make([]float32, mesh.stride)
+	copy(values, mesh.arrayBuf.data[index*mesh.stride:])
+	return values, nil
+}
+
+// SetVertices will replace the vertices starting at the start index with the
+// vertices given as a flat array like NewMesh.
+func (mesh *Mesh) SetVertices(start int, vertices []float32) error {
+	if len(vertices)%mesh.stride != 0 {
+		return fmt.Errorf("vertices do not match the vertex format of %v values per vertex", mesh.stride)
+	} else if start < 0 || start+len(vertices)/mesh.stride > mesh.vertexCount {
+		return fmt.Errorf("vertices from %v to %v are out of range", start, start+len(vertices)/mesh.stride)
+	}
+	mesh.arrayBuf.fill(start*mesh.stride, vertices)
+	return nil
+}
+
+// SetVertexMap will set the order that vertices are drawn in with an index buffer
+// so that vertices can be reused. Indices start at 0.
+func (mesh *Mesh) SetVertexMap(indices []uint32) error {
+	for _, index := range indices {
+		if int(index) >= mesh.vertexCount {
+			return fmt.Errorf("invalid vertex index %v in vertex map", index)
+		}
+	}
+	mesh.vertexMap = indices
+	mesh.indices = newIndexBuffer(indices)
+	return nil
+}
+
+// ClearVertexMap will remove the vertex map so that vertices are drawn in order
+func (mesh *Mesh) ClearVertexMap() {
+	mesh.vertexMap = nil
+	mesh.indices = nil
+}
+
+// GetVertexMap will return the vertex map or nil if there is not one
+func (mesh *Mesh) GetVertexMap() []uint32 {
+	return mesh.vertexMap
+}
+
+// SetTexture will set the texture used when drawing the mesh. If the texture is nil
+// the mesh is drawn untextured.
+func (mesh *Mesh) SetTexture(texture ITexture) {
+	mesh.texture = texture
+}
+
+// GetTexture will return the texture of the mesh or nil if it has none.
+func (mesh *Mesh) GetTexture() ITexture {
+	return mesh.texture
+}
+
+// SetDrawMode will set how the vertices are drawn
+func (mesh *Mesh) SetDrawMode(mode MeshDrawMode) {
+	mesh.mode = mode
+}
+
+// GetDrawMode will return how the vertices are drawn
+func (mesh *Mesh) GetDrawMode() MeshDrawMode {
+	return mesh.mode
+}
+
+// SetDrawRange will set a range of the vertices, or the vertex map if it has one,
+// to draw. This is useful if you only need to render a portion of the mesh.
+func (mesh *Mesh) SetDrawRange(min, max int) error {
+	if min < 0 || max < 0 || min > max {
+		return fmt.Errorf("invalid draw range")
+	}
+	mesh.rangeMin = min
+	mesh.rangeMax = max
+	return nil
+}
+
+// ClearDrawRange will reset the draw range if you want to draw the whole mesh again.
+func (mesh *Mesh) ClearDrawRange() {
+	mesh.rangeMin = -1
+	mesh.rangeMax = -1
+}
+
+// GetDrawRange will return the min, max range of vertices that will be drawn.
+func (mesh *Mesh) GetDrawRange() (int, int) {
+	max := mesh.vertexCount - 1
+	if mesh.indices != nil {
+		max = len(mesh.vertexMap) - 1
+	}
+	min := 0
+	if mesh.rangeMax >= 0 && mesh.rangeMax < max {
+		max = mesh.rangeMax
+	}
+	if mesh.rangeMin >= 0 {
+		min = mesh.rangeMin
+		if min > max {
+			min = max
+		}
+	}
+	return min, max
+}
+
+// SetInstances will attach instances to the mesh so that it is drawn once for
+// every instance. If instances is nil the mesh is drawn once.
+func (mesh *Mesh) SetInstances(instances *Instances) {
+	mesh.instances = instances
+}
+
+// GetInstances will return the instances attached to the mesh or nil if it has none.
+func (mesh *Mesh) GetInstances() *Instances {
+	return mesh.instances
+}
+
+// Draw satisfies the Drawable interface. Inputs are as follows
+// x, y, r, sx, sy, ox, oy, kx, ky
+// x, y are position
+// r is rotation
+// sx, sy is the scale, if sy is not given sy will equal sx
+// ox, oy are offset
+// kx, ky are the shear. If ky is not given ky will equal kx
+func (mesh *Mesh) Draw(args ...float32) {
+	min, max := mesh.GetDrawRange()
+	if max < min || (mesh.instances != nil && mesh.instances.count == 0) {
+		return
+	}
+
+	if model := generateModelMatFromArgs(args); mesh.instances != nil && glState.instancing {
+		defer useInstancedShader(model)()
+	} else {
+		prepareDraw(model)
+	}
+	if mesh.texture != nil {
+		bindTexture(mesh.texture.getHandle())
+	} else {
+		bindTexture(glState.defaultTexture)
+	}
+
+	if mesh.instances != nil && !glState.instancing {
+		mesh.drawExpanded(min, max)
+		return
+	}
+
+	defer useAttributes(mesh.arrayBuf, mesh.format, mesh.stride)()
+	count := max - min + 1
+	if mesh.instances != nil {
+		mesh.instances.bind()
+		defer mesh.instances.unbind()
+		if mesh.indices != nil {
+			mesh.indices.drawElementsInstanced(uint32(mesh.mode), min, count, mesh.instances.count)
+		} else {
+			mesh.instances.drawArrays(gl.Enum(mesh.mode), min, count)
+		}
+	} else if mesh.indices != nil {
+		mesh.indices.drawElements(uint32(mesh.mode), min, count)
+	} else {
+		gl.DrawArrays(gl.Enum(mesh.mode), min, count)
+		countDraw(count)
+	}
+}
+
+// drawExpanded will draw the instances of the mesh without instancing support by
+// expanding them into triangles, or points, on the cpu.
+func (mesh *Mesh) drawExpanded(min, max int) {
+	vertices, mode := mesh.triangulate(min, max)
+	layout, format := mesh.layout()
+	expanded, stride := mesh.instances.expand(vertices, layout)
+	defer useAttributes(mesh.instances.expandedBuffer(expanded), format, stride)()
+	gl.DrawArrays(gl.Enum(mode), 0, len(expanded)/stride)
+	countDraw(len(expanded) / stride)
+}
+
+// triangulate will return the vertices in the draw range in the order they are
+// drawn as separate triangles, or points, so that they can be repeated.
+func (mesh *Mesh) triangulate(min, max int) ([]float32, MeshDrawMode) {
+	indices := make([]uint32, 0, max-min+1)
+	for i := min; i <= max; i++ {
+		if mesh.vertexMap != nil {
+			indices = append(indices, mesh.vertexMap[i])
+		} else {
+			indices = append(indices, uint32(i))
+		}
+	}
+
+	mode := MeshTriangles
+	switch mesh.mode {
+	case MeshFan:
+		fan := []uint32{}
+		for i := 1; i+1 < len(indices); i++ {
+			fan = append(fan, indices[0], indices[i], indices[i+1])
+		}
+		indices = fan
+	case MeshStrip:
+		strip := []uint32{}
+		for i := 0; i+2 < len(indices); i++ {
+			strip = append(strip, indices[i], indices[i+1], indices[i+2])
+		}
+		indices = strip
+	case MeshPoints:
+		mode = MeshPoints
+	}
+
+	vertices := make([]float32, 0, len(indices)*mesh.stride)
+	for _, index := range indices {
+		start := int(index) * mesh.stride
+		vertices = append(vertices, mesh.arrayBuf.data[start:start+mesh.stride]...)
+	}
+	return vertices, mode
+}
+
+// layout will return where instances change the vertices of the mesh and the
+// format of the expanded vertices, which always have a color.
+func (mesh *Mesh) layout() (vertexLayout, []VertexAttribute) {
+	layout := vertexLayout{stride: mesh.stride, position: -1, texCoord: -1, color: -1}
+	offset := 0
+	for _, attrib := range mesh.format {
+		switch {
+		case attrib.Name == "VertexPosition" && attrib.Components >= 2:
+			layout.position = offset
+		case attrib.Name == "VertexTexCoord" && attrib.Components >= 2:
+			layout.texCoord = offset
+		case attrib.Name == "VertexColor":
+			layout.color = offset
+			layout.colorComponents = attrib.Components
+		}
+		offset += attrib.Components
+	}
+	format := mesh.format
+	if layout.color < 0 {
+		format = append(append([]VertexAttribute{}, mesh.format...), VertexAttribute{Name: "VertexColor", Components: 4})
+	}
+	return layout, format
+}
+
+// useAttributes will bind the buffer and point each attribute in the format at its
+// place in it. Builtin attributes have fixed locations, custom attributes are
+// looked up in the current shader and are skipped if the shader does not use them.
+// The returned func will unbind them again.
+func useAttributes(buffer *vertexBuffer, format []VertexAttribute, stride int) func() {
+	buffer.bind()
+	builtins, custom := []gl.Attrib{}, []gl.Attrib{}
+	offset := 0
+	for _, attrib := range format {
+		location, ok := builtinAttributes[attrib.Name]
+		if ok {
+			builtins = append(builtins, location)
+		} else if location, ok = glState.currentShader.getAttribLocation(attrib.Name); ok {
+			custom = append(custom, location)
+		}
+		if ok {
+			gl.VertexAttribPointer(location, attrib.Components, gl.FLOAT, false, stride*4, offset*4)
+		}
+		offset += attrib.Components
+	}
+
+	useVertexAttribArrays(builtins...)
+	for _, attrib := range custom {
+		gl.EnableVertexAttribArray(attrib)
+	}
+	return func() {
+		for _, attrib := range custom {
+			gl.DisableVertexAttribArray(attrib)
+		}
+		buffer.unbind()
+	}
+}
@@ -37,7 +37,38 @@ func InitContext(window *glfw.Window) {
 	if glState.initialized {
 		return
 	}
+	w, h := window.GetFramebufferSize()
+	initContext(int32(w), int32(h))
+	callbackHandlers(window)
+}
+
+// InitOffscreenContext will initiate the opengl context like InitContext for a
+// context that has no window, like a software context used to render tests. The
+// context must already be current and have a default framebuffer of w x h.
+func InitOffscreenContext(w, h int32) {
+	if glState.initialized {
+		return
+	}
+	initContext(w, h)
+}
+
+// ContextWatcher should be passed to glfw.Init so that gl, and the extensions
+// that gl does not wrap, are set up when a context is made current.
+var ContextWatcher = contextWatcher{}
+
+type contextWatcher struct{}
+
+func (contextWatcher) OnMakeCurrent(context interface{}) {
+	gl.ContextWatcher.OnMakeCurrent(context)
+	setCurrentContext(context)
+}
+
+func (contextWatcher) OnDetach() {
+	gl.ContextWatcher.OnDetach()
+	setCurrentContext(nil)
+}
 
+func initContext(w, h int32) {
 	//Get system info
 	glState.defaultFBO = gl.GetBoundFramebuffer()
 	gl.GetIntegerv(gl.VIEWPORT, glState.viewport)
@@ -54,6 +85,8 @@ func InitContext(window *glfw.Window) {
 	gl.VertexAttrib4fv(shaderColor, glcolor)
 	gl.VertexAttrib4fv(shaderConstantColor, glcolor)
 	useVertexAttribArrays()
+	glState.instancingSupported = supportsInstancing()
+	glState.instancing = glState.instancingSupported
 
 	// Enable blending
 	gl.Enable(gl.BLEND)
@@ -67,8 +100,7 @@ func InitContext(window *glfw.Window) {
 	glState.projectionStack = matstack.NewMatStack()
 	glState.viewStack = matstack.NewMatStack() //stacks are initialized with ident matricies on top
 
-	w, h := window.GetFramebufferSize()
-	SetViewportSize(int32(w), int32(h))
+	SetViewportSize(w, h)
 	SetBackgroundColor(0, 0, 0, 1)
 
 	glState.boundTextures = make([]gl.Texture, maxTextureUnits)
@@ -83,7 +115,6 @@ func InitContext(window *glfw.Window) {
 
 	glState.initialized = true
 
-	callbackHandlers(window)
 	loadAllVolatile()
 
 	//have to set this after loadallvolatile() so we are sure the  default shader is loaded
@@ -117,8 +148,10 @@ func createDefaultTexture() {
 	gl.TexImage2D(gl.TEXTURE_2D, 0, 1, 1, gl.RGBA, gl.UNSIGNED_BYTE, []byte{255, 255, 255, 255})
 }
 
-// prepareDraw will upload all the transformations to the current shader
+// prepareDraw will draw anything batched and upload all the transformations to
+// the current shader
 func prepareDraw(model *mgl32.Mat4) {
+	flushBatch()
 	if model == nil {
 		model = &modelIdent
 	}
@@ -128,7 +161,13 @@ func prepareDraw(model *mgl32.Mat4) {
 	// glState.currentShader.SendMat4("ProjectionMat", glState.projectionStack.Peek())
 	// glState.currentShader.SendMat4("ViewMat", glState.viewStack.Peek())
 	// glState.currentShader.SendMat4("ModelMat", *model)
-	glState.currentShader.SendMat4("TransformMat", pmMat)
+	sendDrawUniforms(pmMat)
+}
+
+// sendDrawUniforms will upload the transform, screen size and point size to the
+// current shader
+func sendDrawUniforms(transform mgl32.Mat4) {
+	glState.currentShader.SendMat4("TransformMat", transform)
 	if glState.currentCanvas != nil {
 		glState.currentShader.SendFloat("ScreenSize", float32(screenWidth), float32(screenHeight), 1, 0)
 	} else {
@@ -183,6 +222,7 @@ func bindTexture(texture gl.Texture) {
 	if texture != glState.boundTextures[glState.curTextureUnit] {
 		glState.boundTextures[glState.curTextureUnit] = texture
 		gl.BindTexture(gl.TEXTURE_2D, texture)
+		frameStats.TextureBinds++
 	}
 }
 
@@ -196,6 +236,7 @@ func bindTextureToUnit(texture gl.Texture, textureunit int, restoreprev bool) er
 		}
 		glState.boundTextures[textureunit] = texture
 		gl.BindTexture(gl.TEXTURE_2D, glState.boundTextures[textureunit])
+		frameStats.TextureBinds++
 		if restoreprev {
 			return setTextureUnit(oldtextureunit)
 		}
@@ -209,6 +250,19 @@ func HasFramebufferSRGB() bool {
 	return glState.framebufferSRGBEnabled
 }
 
+// HasInstancing will return true if instances are drawn by the gpu. If it is not
+// supported instances are still drawn but they are expanded on the cpu.
+func HasInstancing() bool {
+	return glState.instancing
+}
+
+// SetInstancing will turn drawing instances on the gpu on or off. When it is off
+// instances are expanded on the cpu like they are when the gpu does not support
+// instancing, so it can only be turned on if it is supported.
+func SetInstancing(enabled bool) {
+	glState.instancing = enabled && glState.instancingSupported
+}
+
 // getDefaultFBO will return the framebuffer that was bound at startup.
 func getDefaultFBO() gl.Framebuffer {
 	return glState.defaultFBO
@@ -217,6 +271,9 @@ func getDefaultFBO() gl.Framebuffer {
 // deleteTexture will clean up the texture if it was bound before and also clean
 // up the open gl data.
 func deleteTexture(texture gl.Texture) {
+	if texture == stream.texture {
+		flushBatch()
+	}
 	// glDeleteTextures binds texture 0 to all texture units the deleted texture
 	// was bound to before deletion.
 	for i, texid := range glState.boundTextures {
@@ -246,6 +303,7 @@ func SetViewportSize(w, h int32) {
 // directly with opengl and used by the framework. Only use this if you know what
 // you are doing
 func SetViewport(x, y, w, h int32) {
+	flushBatch()
 	screenWidth, screenHeight = w, h
 	gl.Viewport(int(y), int(x), int(w), int(h))
 	glState.viewport = []int32{y, x, w, h}
@@ -273,6 +331,7 @@ func GetDimensions() (float32, float32) {
 // Clear will clear everything already rendered to the screen and set is all to
 // the r, g, b, a provided.
 func Clear(r, g, b, a float32) {
+	flushBatch()
 	gl.ClearColor(r, g, b, a)
 	gl.Clear(gl.COLOR_BUFFER_BIT | gl.STENCIL_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 }
@@ -284,6 +343,7 @@ func Present() {
 	if !glState.initialized {
 		return
 	}
+	flushBatch()
 
 	// Make sure we don't have a canvas active.
 	canvas := states.back().canvas
@@ -293,6 +353,7 @@ func Present() {
 
 	// Cleanup after each loop
 	cleanupVolatile()
+	resetStats()
 }
 
 // Origin will reset all translations and transformations back to defaults.
@@ -353,6 +414,9 @@ func Push() {
 // This function is always used to reverse a previous push operation. It returns
 // the current transformation state to what it was before the last preceding push.
 func Pop() {
+	if len(states.stack) > 1 && states.stack[len(states.stack)-2].pointSize != states.back().pointSize {
+		flushBatch()
+	}
 	glState.viewStack.Pop()
 	states.pop()
 }
@@ -363,6 +427,12 @@ func Pop() {
 // (translate, scale, ...). if no arguments are given it will disable the scissor.
 // if x, y, w, h are given it will enable the scissor
 func SetScissor(x, y, width, height int32) {
+	flushBatch()
+	states.back().scissorBox = []int32{x, y, width, height}
+	states.back().scissor = true
+	if glState.headless {
+		return
+	}
 	gl.Enable(gl.SCISSOR_TEST)
 	if glState.currentCanvas != nil {
 		gl.Scissor(x, y, width, height)
@@ -371,14 +441,15 @@ func SetScissor(x, y, width, height int32) {
 		// from the lower left of the viewport instead of the top left.
 		gl.Scissor(x, glState.viewport[3]-(y+height), width, height)
 	}
-	states.back().scissorBox = []int32{x, y, width, height}
-	states.back().scissor = true
 }
 
 // ClearScissor will disable all set scissors.
 func ClearScissor() {
-	gl.Disable(gl.SCISSOR_TEST)
+	flushBatch()
 	states.back().scissor = false
+	if !glState.headless {
+		gl.Disable(gl.SCISSOR_TEST)
+	}
 }
 
 // Stencil operates like stencil but with access to change the stencil action,
@@ -395,6 +466,7 @@ func ClearScissor() {
 // will also re-set all stencil values.
 func Stencil(stencilFunc func(), action StencilAction, value int32, keepvalues bool) {
 	// StencilReplace, 1, false
+	flushBatch()
 	glState.writingToStencil = true
 	if !keepvalues {
 		gl.Clear(gl.STENCIL_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
@@ -408,6 +480,7 @@ func Stencil(stencilFunc func(), action StencilAction, value int32, keepvalues b
 	gl.StencilOp(gl.KEEP, gl.KEEP, gl.Enum(action))
 
 	stencilFunc()
+	flushBatch()
 
 	glState.writingToStencil = false
 	mask := states.back().colorMask
@@ -425,9 +498,12 @@ func SetStencilTest(compare CompareMode, value int32) {
 		return
 	}
 
+	flushBatch()
 	states.back().stencilCompare = compare
 	states.back().stencilTestValue = value
-	if compare == CompareAlways {
+	if glState.headless {
+		return
+	} else if compare == CompareAlways {
 		gl.Disable(gl.STENCIL_TEST)
 		return
 	}
@@ -458,8 +534,11 @@ func GetScissor() (x, y, w, h int32) {
 
 // SetColorMask will set a mask for each r, g, b, and alpha component.
 func SetColorMask(r, g, b, a bool) {
-	gl.ColorMask(r, g, b, a)
+	flushBatch()
 	states.back().colorMask = ColorMask{r, g, b, a}
+	if !glState.headless {
+		gl.ColorMask(r, g, b, a)
+	}
 }
 
 // GetColorMask will return the current color mask
@@ -491,6 +570,9 @@ func GetLineJoin() string {
 
 // SetPointSize will set the size of points drawn by Point
 func SetPointSize(size float32) {
+	if size != states.back().pointSize {
+		flushBatch()
+	}
 	states.back().pointSize = size
 }
 
@@ -508,7 +590,14 @@ func SetShader(shader *Shader) {
 	} else {
 		states.back().shader = shader
 	}
-	states.back().shader.attach(false)
+	if !glState.headless {
+		states.back().shader.attach(false)
+	}
+}
+
+// GetShader returns the currently bound shader
+func GetShader() *Shader {
+	return states.back().shader
 }
 
 // SetBackgroundColor sets the background color.
@@ -524,7 +613,9 @@ func GetBackgroundColor() []float32 {
 // SetColor will sets the color used for drawing.
 func SetColor(r, g, b, a float32) {
 	states.back().color = []float32{r, g, b, a}
-	gl.VertexAttrib4f(shaderConstantColor, r, g, b, a)
+	if !glState.headless {
+		gl.VertexAttrib4f(shaderConstantColor, r, g, b, a)
+	}
 }
 
 // GetColor returns the current drawing color.
@@ -550,7 +641,11 @@ func GetFont() *Font {
 // until the next SetCanvas call will be redirected to the Canvas and not shown
 // on the screen. Call with a no params to enable drawing to screen again.
 func SetCanvas(canvas *Canvas) error {
+	flushBatch()
 	states.back().canvas = canvas
+	if glState.headless {
+		return nil
+	}
 
 	if canvas != nil {
 		return canvas.startGrab()
@@ -571,6 +666,11 @@ func GetCanvas() *Canvas {
 // SetBlendMode sets the blending mode. Blending modes are different ways to do
 // color blending. See BlendMode constants to see how they operate.
 func SetBlendMode(mode string) {
+	flushBatch()
+	states.back().blendMode = mode
+	if glState.headless {
+		return
+	}
 	fn := gl.FUNC_ADD
 	srcRGB := gl.ONE
 	srcA := gl.ONE
@@ -617,5 +717,4 @@ func SetBlendMode(mode string) {
 
 	gl.BlendEquation(gl.Enum(fn))
 	gl.BlendFuncSeparate(gl.Enum(srcRGB), gl.Enum(dstRGB), gl.Enum(srcA), gl.Enum(dstA))
-	states.back().blendMode = mode
 }
@@ -77,16 +77,7 @@ func (polyline *polyLine) render(coords []float32) {
 		vertices = append(vertices, polyline.renderEdge(sleeve, next, []float32{next[0] + sleeve[0], next[1] + sleeve[1]})...)
 	}
 
-	prepareDraw(nil)
-	bindTexture(glState.defaultTexture)
-	useVertexAttribArrays(shaderPos)
-
-	buffer := newVertexBuffer(len(vertices), vertices, UsageStatic)
-	buffer.bind()
-	defer buffer.unbind()
-
-	gl.VertexAttribPointer(gl.Attrib{Value: 0}, 2, gl.FLOAT, false, 0, 0)
-	gl.DrawArrays(gl.TRIANGLE_STRIP, 0, len(vertices)/2)
+	batchDraw(gl.TRIANGLE_STRIP, glState.defaultTexture, nil, 2, vertices)
 }
 
 func (polyline *polyLine) renderEdge(sleeve, current, next []float32) []float32 {
@@ -32,6 +32,14 @@ func (buffer *indexBuffer) drawElements(mode uint32, offset, size int) {
 	buffer.bind()
 	defer buffer.unbind()
 	gl.DrawElements(gl.Enum(mode), size, gl.UNSIGNED_INT, offset*4)
+	countDraw(size)
+}
+
+func (buffer *indexBuffer) drawElementsInstanced(mode uint32, offset, size, instances int) {
+	buffer.bind()
+	defer buffer.unbind()
+	drawElementsInstanced(gl.Enum(mode), size, gl.UNSIGNED_INT, offset*4, instances)
+	countDraw(size * instances)
 }
 
 func (buffer *indexBuffer) loadVolatile() bool {
@@ -114,3 +122,7 @@ func newAltQuadIndices(size int) *quadIndices {
 func (qi *quadIndices) drawElements(mode uint32, offset, size int) {
 	qi.indexBuffer.drawElements(mode, offset*6, size*6)
 }
+
+func (qi *quadIndices) drawElementsInstanced(mode uint32, offset, size, instances int) {
+	qi.indexBuffer.drawElementsInstanced(mode, offset*6, size*6, instances)
+}
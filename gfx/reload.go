@@ -0,0 +1,108 @@
+package gfx
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// reloader is a resource that was loaded from a file and can be loaded again
+// when that file changes.
+type reloader interface {
+	reload() error
+}
+
+var (
+	hotReload  bool
+	reloadable = map[string][]reloader{}
+)
+
+// SetHotReload enables tracking which files images, shaders and fonts are loaded
+// from so that they can be reloaded with ReloadFile. Tracked resources are kept
+// in memory until ResetHotReload is called so this is only meant for development.
+func SetHotReload(enabled bool) {
+	hotReload = enabled
+	ResetHotReload()
+}
+
+// ResetHotReload will forget all the tracked resources. This should be called
+// when the program that loaded them has been discarded.
+func ResetHotReload() {
+	reloadable = map[string][]reloader{}
+}
+
+// SaveHotReload will return a function that puts back the resources that are
+// currently tracked, so that they are not forgotten if the program that was meant
+// to replace them fails to load.
+func SaveHotReload() (restore func()) {
+	saved := reloadable
+	return func() { reloadable = saved }
+}
+
+// ReloadFile will reload every image, shader and font that was loaded from the
+// file at path. It returns how many resources were reloaded.
+func ReloadFile(path string) (int, error) {
+	resources := reloadable[reloadKey(path)]
+	for _, res := range resources {
+		if err := res.reload(); err != nil {
+			return 0, err
+		}
+	}
+	return len(resources), nil
+}
+
+func trackFile(path string, res reloader) {
+	if hotReload && path != "" {
+		key := reloadKey(path)
+		reloadable[key] = append(reloadable[key], res)
+	}
+}
+
+func reloadKey(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// reload will replace the texture with a newly loaded one, keeping the wrap and
+// filter settings of the old texture.
+func (img *Image) reload() error {
+	old := img.Texture
+	if !img.loadVolatile() {
+		return fmt.Errorf("could not reload image %v", img.filePath)
+	}
+	if old != nil {
+		img.Texture.SetWrap(old.wrap.s, old.wrap.t)
+		img.Texture.SetFilter(old.filter.min, old.filter.mag)
+		old.unloadVolatile()
+	}
+	return nil
+}
+
+// reload will read the shader code from its files again and recompile it. If
+// the new code fails to compile the old program is kept.
+func (shader *Shader) reload() (err error) {
+	reloaded := &Shader{paths: shader.paths}
+	reloaded.vertexCode, reloaded.fragmentCode = shaderCodeToGLSL(pathsToCode(shader.paths...)...)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("could not reload shader: %v", r)
+		}
+	}()
+	reloaded.loadVolatile()
+
+	shader.unloadVolatile()
+	*shader = *reloaded
+	if glState.currentShader == shader {
+		glState.currentShader = nil
+		shader.attach(false)
+	}
+	return nil
+}
+
+// reload will rasterize the font from its file again. Fallback fonts are kept.
+func (font *Font) reload() error {
+	face, err := font.loadFace()
+	if err != nil {
+		return err
+	}
+	font.rasterizers[0] = newRasterizer(face, font.runeSets...)
+	return nil
+}
@@ -15,21 +15,33 @@ import (
 
 // Shader is a glsl program that can be applied while drawing.
 type Shader struct {
+	paths          []string
 	vertexCode     string
 	fragmentCode   string
 	program        gl.Program
 	uniforms       map[string]uniform // uniform location buffer map
+	attributes     map[string]gl.Attrib
 	texUnitPool    map[string]int
 	activeTexUnits []gl.Texture
+	instanced      *Shader                        // variant with the per instance attributes, made on the first instanced draw
+	sent           map[string]func(*Shader) error // uniforms sent by the user to send again to the instanced variant
 }
 
 // NewShader will create a new shader program. It takes in either paths to glsl
-// files or shader code directly
+// files or shader code directly. Vertex code can declare attributes that match
+// the custom attributes in the vertex format of a Mesh to receive their values.
+// Instances are drawn with a variant of the shader that uses 4 more attributes,
+// which leaves 4 fewer for custom attributes in an instanced mesh.
 func NewShader(paths ...string) *Shader {
-	newShader := &Shader{}
+	newShader := &Shader{paths: paths}
 	code := pathsToCode(paths...)
 	newShader.vertexCode, newShader.fragmentCode = shaderCodeToGLSL(code...)
 	registerVolatile(newShader)
+	for _, path := range paths {
+		if !isVertexCode(path) && !isFragmentCode(path) {
+			trackFile(path, newShader)
+		}
+	}
 	return newShader
 }
 
@@ -47,6 +59,12 @@ func (shader *Shader) loadVolatile() bool {
 	gl.BindAttribLocation(shader.program, shaderTexCoord, "VertexTexCoord")
 	gl.BindAttribLocation(shader.program, shaderColor, "VertexColor")
 	gl.BindAttribLocation(shader.program, shaderConstantColor, "ConstantColor")
+	if shader.isInstanced() {
+		gl.BindAttribLocation(shader.program, shaderInstanceTransform, "InstanceTransform")
+		gl.BindAttribLocation(shader.program, shaderInstanceOffset, "InstanceOffset")
+		gl.BindAttribLocation(shader.program, shaderInstanceColor, "InstanceColor")
+		gl.BindAttribLocation(shader.program, shaderInstanceQuad, "InstanceQuad")
+	}
 
 	gl.LinkProgram(shader.program)
 	gl.DeleteShader(vert)
@@ -58,6 +76,7 @@ func (shader *Shader) loadVolatile() bool {
 	}
 
 	shader.mapUniforms()
+	shader.mapAttributes()
 
 	return true
 }
@@ -96,10 +115,80 @@ func (shader *Shader) mapUniforms() {
 	}
 }
 
+// mapAttributes will find the locations of all the attributes used by the shader
+// so that custom mesh attributes can be bound to them.
+func (shader *Shader) mapAttributes() {
+	shader.attributes = map[string]gl.Attrib{}
+	for i := 0; i < gl.GetProgrami(shader.program, gl.ACTIVE_ATTRIBUTES); i++ {
+		name, _, _ := gl.GetActiveAttrib(shader.program, uint32(i))
+		shader.attributes[name] = gl.GetAttribLocation(shader.program, name)
+	}
+}
+
+// getAttribLocation will return the location of the attribute with the name and
+// false if the shader does not use it.
+func (shader *Shader) getAttribLocation(name string) (gl.Attrib, bool) {
+	location, ok := shader.attributes[name]
+	return location, ok
+}
+
+// instancedVariant will return the shader compiled with the per instance
+// attributes, creating it the first time. Uniforms already sent to the shader
+// are sent to the variant as well.
+func (shader *Shader) instancedVariant() *Shader {
+	if shader.instanced == nil {
+		shader.instanced = &Shader{
+			vertexCode:   instancedDefine + shader.vertexCode,
+			fragmentCode: shader.fragmentCode,
+		}
+		registerVolatile(shader.instanced)
+		for _, send := range shader.sent {
+			send(shader.instanced)
+		}
+	}
+	return shader.instanced
+}
+
+// isInstanced will return true if this is the instanced variant of a shader
+func (shader *Shader) isInstanced() bool {
+	return strings.HasPrefix(shader.vertexCode, instancedDefine)
+}
+
+// keep will remember a uniform sent by the user so that it can be sent to the
+// instanced variant, which is a separate program with its own uniforms. The
+// uniforms sent for every draw are skipped.
+func (shader *Shader) keep(name string, send func(*Shader) error) {
+	switch name {
+	case "TransformMat", "ScreenSize", "PointSize":
+		return
+	}
+	if shader.sent == nil {
+		shader.sent = map[string]func(*Shader) error{}
+	}
+	shader.sent[name] = send
+	if shader.instanced != nil {
+		send(shader.instanced)
+	}
+}
+
+// attachToSend will attach the shader to send a uniform to it and return a
+// function that attaches the shader that was in use again, which can be the
+// instanced variant of a shader while it draws.
+func (shader *Shader) attachToSend() func() {
+	previous := glState.currentShader
+	if previous == nil {
+		previous = states.back().shader
+	}
+	shader.attach(true)
+	return func() { previous.attach(false) }
+}
+
 func (shader *Shader) attach(temporary bool) {
+	flushBatch()
 	if glState.currentShader != shader {
 		gl.UseProgram(shader.program)
 		glState.currentShader = shader
+		frameStats.ShaderSwitches++
 	}
 	if !temporary {
 		// make sure all sent textures are properly bound to their respective texture units
@@ -141,8 +230,7 @@ func (shader *Shader) getUniformAndCheck(name string, expected UniformType, coun
 // SendInt allows you to pass in integer values into your shader, by the name of
 // the variable
 func (shader *Shader) SendInt(name string, values ...int32) error {
-	shader.attach(true)
-	defer states.back().shader.attach(false)
+	defer shader.attachToSend()()
 
 	u, err := shader.getUniformAndCheck(name, UniformInt, len(values))
 	if err != nil {
@@ -152,25 +240,23 @@ func (shader *Shader) SendInt(name string, values ...int32) error {
 	switch u.TypeSize {
 	case 4:
 		gl.Uniform4iv(u.Location, values)
-		return nil
 	case 3:
 		gl.Uniform3iv(u.Location, values)
-		return nil
 	case 2:
 		gl.Uniform2iv(u.Location, values)
-		return nil
 	case 1:
 		gl.Uniform1iv(u.Location, values)
-		return nil
+	default:
+		return errors.New("Invalid type size for uniform: " + name)
 	}
-	return errors.New("Invalid type size for uniform: " + name)
+	shader.keep(name, func(s *Shader) error { return s.SendInt(name, values...) })
+	return nil
 }
 
 // SendFloat allows you to pass in float32 values into your shader, by the name of
 // the variable
 func (shader *Shader) SendFloat(name string, values ...float32) error {
-	shader.attach(true)
-	defer states.back().shader.attach(false)
+	defer shader.attachToSend()()
 
 	u, err := shader.getUniformAndCheck(name, UniformFloat, len(values))
 	if err != nil {
@@ -180,25 +266,23 @@ func (shader *Shader) SendFloat(name string, values ...float32) error {
 	switch u.TypeSize {
 	case 4:
 		gl.Uniform4fv(u.Location, values)
-		return nil
 	case 3:
 		gl.Uniform3fv(u.Location, values)
-		return nil
 	case 2:
 		gl.Uniform2fv(u.Location, values)
-		return nil
 	case 1:
 		gl.Uniform1fv(u.Location, values)
-		return nil
+	default:
+		return errors.New("Invalid type size for uniform: " + name)
 	}
-	return errors.New("Invalid type size for uniform: " + name)
+	shader.keep(name, func(s *Shader) error { return s.SendFloat(name, values...) })
+	return nil
 }
 
 // SendMat4 allows you to pass in a 4x4 matrix value into your shader, by the name of
 // the variable
 func (shader *Shader) SendMat4(name string, mat mgl32.Mat4) error {
-	shader.attach(true)
-	defer states.back().shader.attach(false)
+	defer shader.attachToSend()()
 
 	u, err := shader.getUniformAndCheck(name, UniformFloat, 4)
 	if err != nil {
@@ -210,14 +294,14 @@ func (shader *Shader) SendMat4(name string, mat mgl32.Mat4) error {
 		mat[8], mat[9], mat[10], mat[11],
 		mat[12], mat[13], mat[14], mat[15],
 	})
+	shader.keep(name, func(s *Shader) error { return s.SendMat4(name, mat) })
 	return nil
 }
 
 // SendMat3 allows you to pass in a 3x3 matrix value into your shader, by the name of
 // the variable
 func (shader *Shader) SendMat3(name string, mat mgl32.Mat3) error {
-	shader.attach(true)
-	defer states.back().shader.attach(false)
+	defer shader.attachToSend()()
 
 	u, err := shader.getUniformAndCheck(name, UniformFloat, 3)
 	if err != nil {
@@ -228,14 +312,14 @@ func (shader *Shader) SendMat3(name string, mat mgl32.Mat3) error {
 		mat[3], mat[4], mat[5],
 		mat[6], mat[7], mat[8],
 	})
+	shader.keep(name, func(s *Shader) error { return s.SendMat3(name, mat) })
 	return nil
 }
 
 // SendMat2 allows you to pass in a 2x2 matrix value into your shader, by the name of
 // the variable
 func (shader *Shader) SendMat2(name string, mat mgl32.Mat2) error {
-	shader.attach(true)
-	defer states.back().shader.attach(false)
+	defer shader.attachToSend()()
 
 	u, err := shader.getUniformAndCheck(name, UniformFloat, 3)
 	if err != nil {
@@ -245,14 +329,14 @@ func (shader *Shader) SendMat2(name string, mat mgl32.Mat2) error {
 		mat[0], mat[1],
 		mat[2], mat[3],
 	})
+	shader.keep(name, func(s *Shader) error { return s.SendMat2(name, mat) })
 	return nil
 }
 
 // SendTexture allows you to pass in a ITexture to your shader as a sampler, by the name of
 // the variable. This means you can pass in an image but also a canvas.
 func (shader *Shader) SendTexture(name string, texture ITexture) error {
-	shader.attach(true)
-	defer states.back().shader.attach(false)
+	defer shader.attachToSend()()
 
 	gltex := texture.getHandle()
 	texunit := shader.getTextureUnit(name)
@@ -273,6 +357,7 @@ func (shader *Shader) SendTexture(name string, texture ITexture) error {
 
 	// store texture id so it can be re-bound to the proper texture unit later
 	shader.activeTexUnits[texunit-1] = gltex
+	shader.keep(name, func(s *Shader) error { return s.SendTexture(name, texture) })
 
 	return nil
 }
@@ -330,8 +415,8 @@ func isFragmentCode(code string) bool {
 	return match
 }
 
-//convert paths to strings of code
-//if string is already code just pass it along
+// convert paths to strings of code
+// if string is already code just pass it along
 func pathsToCode(paths ...string) []string {
 	code := []string{}
 	if paths != nil {
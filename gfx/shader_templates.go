@@ -19,11 +19,21 @@ uniform vec4 ScreenSize;
 )
 
 const (
+	// instancedDefine is put before the vertex code of the instanced variant of a
+	// shader so that it declares and applies the per instance attributes.
+	instancedDefine = "#define INSTANCED\n"
+
 	vertexHeader = `
 attribute vec4 VertexPosition;
 attribute vec4 VertexTexCoord;
 attribute vec4 VertexColor;
 attribute vec4 ConstantColor;
+#ifdef INSTANCED
+attribute vec4 InstanceTransform;
+attribute vec4 InstanceOffset;
+attribute vec4 InstanceColor;
+attribute vec4 InstanceQuad;
+#endif
 varying vec4 VaryingTexCoord;
 varying vec4 VaryingColor;
 uniform float PointSize;
@@ -36,10 +46,21 @@ vec4 position(mat4 transformMatrix, vec4 vertexPosition) {
 
 	vertexFooter = `
 void main() {
+#ifdef INSTANCED
+	vec2 instancePosition = VertexPosition.xy * InstanceQuad.zw;
+	VaryingTexCoord = vec4(InstanceQuad.xy + VertexTexCoord.st * InstanceQuad.zw, VertexTexCoord.pq);
+	VaryingColor = VertexColor * ConstantColor * InstanceColor;
+	vec4 vertexPosition = vec4(
+		InstanceTransform.xy * instancePosition.x + InstanceTransform.zw * instancePosition.y + InstanceOffset.xy,
+		VertexPosition.zw
+	);
+#else
 	VaryingTexCoord = VertexTexCoord;
 	VaryingColor = VertexColor * ConstantColor;
+	vec4 vertexPosition = VertexPosition;
+#endif
 	gl_PointSize = PointSize;
-	gl_Position = position(TransformMat, VertexPosition);
+	gl_Position = position(TransformMat, vertexPosition);
 }`
 
 	fragmentHeader = `
@@ -0,0 +1,5 @@
+// Package softgl creates a gl context that is rendered in software without a
+// window or a gpu, so that graphics can be rendered and checked on machines with
+// no display, like tests on a ci server. It needs Mesa's OSMesa library so it is
+// only built in with the osmesa build tag.
+package softgl
@@ -0,0 +1,57 @@
+// +build osmesa,!js
+
+package softgl
+
+/*
+#cgo pkg-config: osmesa
+#include <stdlib.h>
+#include <GL/osmesa.h>
+
+static void* getProcAddress(const char* name) {
+	return (void*)OSMesaGetProcAddress(name);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/go-gl/gl/v2.1/gl"
+
+	"github.com/tanema/amore/gfx"
+)
+
+var (
+	context C.OSMesaContext
+	buffer  unsafe.Pointer
+)
+
+// Init will create a software gl context with a framebuffer of width x height and
+// initialize the gfx package with it.
+func Init(width, height int32) error {
+	if context != nil {
+		return nil
+	}
+	context = C.OSMesaCreateContextExt(C.OSMESA_RGBA, 24, 8, 0, nil)
+	if context == nil {
+		return errors.New("could not create an osmesa context")
+	}
+	buffer = C.malloc(C.size_t(width * height * 4))
+	if C.OSMesaMakeCurrent(context, buffer, C.GL_UNSIGNED_BYTE, C.GLsizei(width), C.GLsizei(height)) == 0 {
+		C.OSMesaDestroyContext(context)
+		C.free(buffer)
+		context, buffer = nil, nil
+		return errors.New("could not make the osmesa context current")
+	}
+	err := gl.InitWithProcAddrFunc(func(name string) unsafe.Pointer {
+		cname := C.CString(name)
+		defer C.free(unsafe.Pointer(cname))
+		return C.getProcAddress(cname)
+	})
+	if err != nil {
+		return err
+	}
+	gfx.InitOffscreenContext(width, height)
+	return nil
+}
@@ -0,0 +1,11 @@
+// +build !osmesa js
+
+package softgl
+
+import "errors"
+
+// Init will create a software gl context with a framebuffer of width x height and
+// initialize the gfx package with it.
+func Init(width, height int32) error {
+	return errors.New("software rendering was not built in, rebuild with -tags osmesa")
+}
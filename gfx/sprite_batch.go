@@ -18,6 +18,7 @@ type SpriteBatch struct {
 	quadIndices *quadIndices
 	usage       Usage
 	texture     ITexture
+	instances   *Instances
 	rangeMin    int
 	rangeMax    int
 }
@@ -82,6 +83,17 @@ func (spriteBatch *SpriteBatch) GetTexture() ITexture {
 	return spriteBatch.texture
 }
 
+// SetInstances will attach instances to the batch so that its sprites are drawn
+// once for every instance. If instances is nil the sprites are drawn once.
+func (spriteBatch *SpriteBatch) SetInstances(instances *Instances) {
+	spriteBatch.instances = instances
+}
+
+// GetInstances will return the instances attached to the batch or nil if it has none.
+func (spriteBatch *SpriteBatch) GetInstances() *Instances {
+	return spriteBatch.instances
+}
+
 // SetColor will set the color that will be used for the next add or set operations.
 func (spriteBatch *SpriteBatch) SetColor(vals ...float32) {
 	spriteBatch.color = vals
@@ -126,6 +138,8 @@ func (spriteBatch *SpriteBatch) GetBufferSize() int {
 func (spriteBatch *SpriteBatch) addv(verts []float32, mat *mgl32.Mat4, index int) error {
 	if index == -1 && spriteBatch.count >= spriteBatch.size {
 		return fmt.Errorf("Sprite Batch Buffer Full")
+	} else if index != -1 && (index < 0 || index >= spriteBatch.count) {
+		return fmt.Errorf("invalid sprite index %v", index)
 	}
 
 	sprite := make([]float32, 8*4)
@@ -190,14 +204,24 @@ func (spriteBatch *SpriteBatch) GetDrawRange() (int, int) {
 // ox, oy are offset
 // kx, ky are the shear. If ky is not given ky will equal kx
 func (spriteBatch *SpriteBatch) Draw(args ...float32) {
-	if spriteBatch.count == 0 {
+	if spriteBatch.count == 0 || (spriteBatch.instances != nil && spriteBatch.instances.count == 0) {
 		return
 	}
 
-	prepareDraw(generateModelMatFromArgs(args))
+	if model := generateModelMatFromArgs(args); spriteBatch.instances != nil && glState.instancing {
+		defer useInstancedShader(model)()
+	} else {
+		prepareDraw(model)
+	}
 	bindTexture(spriteBatch.texture.getHandle())
 	useVertexAttribArrays(shaderPos, shaderTexCoord, shaderColor)
 
+	min, max := spriteBatch.GetDrawRange()
+	if spriteBatch.instances != nil && !glState.instancing {
+		spriteBatch.drawExpanded(min, max)
+		return
+	}
+
 	spriteBatch.arrayBuf.bind()
 	defer spriteBatch.arrayBuf.unbind()
 
@@ -205,6 +229,30 @@ func (spriteBatch *SpriteBatch) Draw(args ...float32) {
 	gl.VertexAttribPointer(gl.Attrib{Value: 1}, 2, gl.FLOAT, false, 8*4, 2*4)
 	gl.VertexAttribPointer(gl.Attrib{Value: 2}, 4, gl.FLOAT, false, 8*4, 4*4)
 
-	min, max := spriteBatch.GetDrawRange()
-	spriteBatch.quadIndices.drawElements(gl.TRIANGLES, min, max-min+1)
+	if spriteBatch.instances != nil {
+		spriteBatch.instances.bind()
+		defer spriteBatch.instances.unbind()
+		spriteBatch.quadIndices.drawElementsInstanced(gl.TRIANGLES, min, max-min+1, spriteBatch.instances.count)
+	} else {
+		spriteBatch.quadIndices.drawElements(gl.TRIANGLES, min, max-min+1)
+	}
+}
+
+// drawExpanded will draw the instances of the batch without instancing support
+// by repeating its sprites for every instance on the cpu.
+func (spriteBatch *SpriteBatch) drawExpanded(min, max int) {
+	instances := spriteBatch.instances
+	vertices := spriteBatch.arrayBuf.data[min*4*8 : (max+1)*4*8]
+	expanded, _ := instances.expand(vertices, vertexLayout{stride: 8, position: 0, texCoord: 2, color: 4, colorComponents: 4})
+
+	buffer := instances.expandedBuffer(expanded)
+	buffer.bind()
+	defer buffer.unbind()
+
+	gl.VertexAttribPointer(gl.Attrib{Value: 0}, 2, gl.FLOAT, false, 8*4, 0)
+	gl.VertexAttribPointer(gl.Attrib{Value: 1}, 2, gl.FLOAT, false, 8*4, 2*4)
+	gl.VertexAttribPointer(gl.Attrib{Value: 2}, 4, gl.FLOAT, false, 8*4, 4*4)
+
+	sprites := (max - min + 1) * instances.count
+	instances.expandedQuadIndices(sprites).drawElements(gl.TRIANGLES, 0, sprites)
 }
@@ -0,0 +1,43 @@
+package gfx
+
+// Stats are counts of the work sent to the gpu during a frame
+type Stats struct {
+	DrawCalls      int // draw calls issued
+	TextureBinds   int // textures bound to a texture unit
+	ShaderSwitches int // times a different shader program was used
+	Vertices       int // vertices submitted, or indices for indexed draws
+	CanvasSwitches int // times the render target changed between canvases or the screen
+}
+
+var (
+	frameStats Stats // stats of the frame being drawn
+	lastStats  Stats // stats of the last presented frame
+)
+
+// GetStats will return the stats of the last frame that was presented. Stats are
+// counted from one Present to the next.
+func GetStats() Stats {
+	return lastStats
+}
+
+// countDraw will count a draw call that submitted the number of vertices
+func countDraw(vertices int) {
+	frameStats.DrawCalls++
+	frameStats.Vertices += vertices
+}
+
+// IgnoreStats will call draw without counting any of its work in the stats of the
+// frame. This is for debug overlays that should not change the stats they show.
+func IgnoreStats(draw func()) {
+	flushBatch()
+	saved := frameStats
+	draw()
+	flushBatch()
+	frameStats = saved
+}
+
+// resetStats will finish the stats for the frame that was just presented
+func resetStats() {
+	lastStats = frameStats
+	frameStats = Stats{}
+}
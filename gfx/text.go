@@ -2,6 +2,8 @@ package gfx
 
 import (
 	"strings"
+
+	"github.com/goxjs/gl"
 )
 
 type (
@@ -149,9 +151,11 @@ func (text *Text) Set(strs []string, colors [][]float32) {
 // ox, oy are offset
 // kx, ky are the shear. If ky is not given ky will equal kx
 func (text *Text) Draw(args ...float32) {
-	for _, batch := range text.batches {
-		if batch.GetCount() > 0 {
-			batch.Draw(args...)
+	model := generateModelMatFromArgs(args)
+	for _, glyphs := range text.batches {
+		if glyphs.GetCount() > 0 {
+			vertices := quadsToTriangles(glyphs.arrayBuf.data[:glyphs.GetCount()*4*8], 8)
+			batchDraw(gl.TRIANGLES, glyphs.texture.getHandle(), model, 8, vertices)
 		}
 	}
 }
@@ -146,6 +146,7 @@ func (texture *Texture) generateMipmaps() {
 func (texture *Texture) SetWrap(wrapS, wrapT WrapMode) {
 	texture.wrap.s = wrapS
 	texture.wrap.t = wrapT
+	flushBatch()
 	bindTexture(texture.getHandle())
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, int(wrapS))
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, int(wrapT))
@@ -167,6 +168,7 @@ func (texture *Texture) SetFilter(min, mag FilterMode) error {
 	}
 	texture.filter.min = min
 	texture.filter.mag = mag
+	flushBatch()
 	texture.setTextureFilter()
 	return nil
 }
@@ -243,30 +245,20 @@ func (texture *Texture) loadVolatile() bool {
 	return false
 }
 
-// unloadVolatile release the texture data
+// unloadVolatile release the texture data. It is called when the image or canvas
+// holding the texture is garbage collected or its texture is replaced by a reload.
 func (texture *Texture) unloadVolatile() {
-	if texture != nil {
+	if texture == nil {
 		return
 	}
 	deleteTexture(texture.textureID)
 	texture = nil
 }
 
-// drawv will take in verticies from the public draw calls and draw the texture
+// drawv will take in verticies from the public draw calls and batch the texture
 // with the verticies and the model matrix
 func (texture *Texture) drawv(model *mgl32.Mat4, vertices []float32) {
-	prepareDraw(model)
-	bindTexture(texture.getHandle())
-	useVertexAttribArrays(shaderPos, shaderTexCoord)
-
-	buffer := newVertexBuffer(len(vertices), vertices, UsageStatic)
-	buffer.bind()
-	defer buffer.unbind()
-
-	gl.VertexAttribPointer(shaderPos, 2, gl.FLOAT, false, 4*4, 0)
-	gl.VertexAttribPointer(shaderTexCoord, 2, gl.FLOAT, false, 4*4, 2*4)
-
-	gl.DrawArrays(gl.TRIANGLE_STRIP, 0, 4)
+	batchDraw(gl.TRIANGLE_STRIP, texture.getHandle(), model, 4, vertices)
 }
 
 // Draw satisfies the Drawable interface. Inputs are as follows
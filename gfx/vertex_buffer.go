@@ -20,9 +20,7 @@ func newVertexBuffer(size int, data []float32, usage Usage) *vertexBuffer {
 		usage: usage,
 		data:  make([]float32, size),
 	}
-	if len(data) > 0 {
-		copy(newBuffer.data, data[:size])
-	}
+	copy(newBuffer.data, data)
 	registerVolatile(newBuffer)
 	return newBuffer
 }
@@ -31,8 +29,9 @@ func (buffer *vertexBuffer) bufferStatic() {
 	if buffer.modifiedSize == 0 {
 		return
 	}
-	// Upload the mapped data to the buffer.
-	gl.BufferSubData(gl.ARRAY_BUFFER, buffer.modifiedOffset*4, f32Bytes(buffer.data))
+	// Upload the modified part of the mapped data to the buffer.
+	modified := buffer.data[buffer.modifiedOffset : buffer.modifiedOffset+buffer.modifiedSize]
+	gl.BufferSubData(gl.ARRAY_BUFFER, buffer.modifiedOffset*4, f32Bytes(modified))
 }
 
 func (buffer *vertexBuffer) bufferStream() {
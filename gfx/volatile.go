@@ -16,8 +16,13 @@ var (
 )
 
 // registerVolatile will put the volatile in the current object group and call
-// loadVolatile if the gl context is initialized.
+// loadVolatile if the gl context is initialized. When running headless only the
+// parts that do not need a context are loaded.
 func registerVolatile(newVolatile volatile) {
+	if glState.headless {
+		loadHeadless(newVolatile)
+		return
+	}
 	if !glState.initialized {
 		allVolatile = append(allVolatile, newVolatile)
 		return
@@ -0,0 +1,328 @@
+package wrap
+
+import (
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/gfx"
+)
+
+func toAnimation(ls *lua.LState, offset int) *gfx.Animation {
+	animation := ls.CheckUserData(offset)
+	if v, ok := animation.Value.(*gfx.Animation); ok {
+		return v
+	}
+	ls.ArgError(offset, "animation expected")
+	return nil
+}
+
+// gfxNewAnimation takes a texture, a table of quads and either a duration in
+// seconds for every frame or a table with a duration for each frame.
+func gfxNewAnimation(ls *lua.LState) int {
+	texture := toLoadedTexture(ls, 1)
+	quads := []*gfx.Quad{}
+	ls.CheckTable(2).ForEach(func(_, value lua.LValue) {
+		if ud, ok := value.(*lua.LUserData); ok {
+			if quad, ok := ud.Value.(*gfx.Quad); ok {
+				quads = append(quads, quad)
+				return
+			}
+		}
+		ls.ArgError(2, "table of quads expected")
+	})
+	animation, err := gfx.NewAnimation(texture, quads, extractDurations(ls, 3))
+	if err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return returnUD(ls, "Animation", animation)
+}
+
+// gfxNewGridAnimation takes a texture, the width and height of each frame, an
+// optional table of the grid cells to play and the durations like newanimation.
+func gfxNewGridAnimation(ls *lua.LState) int {
+	frames := []int{}
+	if table, ok := ls.Get(4).(*lua.LTable); ok {
+		table.ForEach(func(_, value lua.LValue) {
+			if cell, ok := value.(lua.LNumber); ok {
+				frames = append(frames, int(cell))
+				return
+			}
+			ls.ArgError(4, "table of frames expected")
+		})
+	}
+	animation, err := gfx.NewGridAnimation(
+		toLoadedTexture(ls, 1),
+		int32(toInt(ls, 2)),
+		int32(toInt(ls, 3)),
+		frames,
+		extractDurations(ls, 5),
+	)
+	if err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return returnUD(ls, "Animation", animation)
+}
+
+// gfxNewAnimationFromJSON takes the path to json exported by Aseprite or
+// TexturePacker and an optional texture. If no texture is given the image named
+// in the json is loaded. It returns nil and an error if the json could not be read.
+func gfxNewAnimationFromJSON(ls *lua.LState) int {
+	var texture gfx.ITexture
+	if ls.Get(2) != lua.LNil {
+		texture = toLoadedTexture(ls, 2)
+	}
+	animation, err := gfx.NewAnimationFromJSON(toString(ls, 1), texture)
+	if err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	return returnUD(ls, "Animation", animation)
+}
+
+// gfxAnimationUpdate advances the animation and calls its callbacks in the state
+// that is updating it, not the one that set them, which may have been closed.
+func gfxAnimationUpdate(ls *lua.LState) int {
+	err := toAnimation(ls, 1).Update(toFloat(ls, 2), func(callback interface{}, args ...int) error {
+		params := make([]lua.LValue, len(args))
+		for i, arg := range args {
+			params[i] = lua.LNumber(arg)
+		}
+		return ls.CallByParam(lua.P{Fn: callback.(*lua.LFunction), Protect: true}, params...)
+	})
+	if err != nil {
+		raiseCallbackError(ls, err)
+	}
+	return 0
+}
+
+func gfxAnimationDraw(ls *lua.LState) int {
+	toAnimation(ls, 1).Draw(extractFloatArray(ls, 2)...)
+	return 0
+}
+
+func gfxAnimationAddTo(ls *lua.LState) int {
+	if err := toAnimation(ls, 1).AddTo(toSpriteBatch(ls, 2), extractFloatArray(ls, 3)...); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxAnimationSetIn(ls *lua.LState) int {
+	animation, batch := toAnimation(ls, 1), toSpriteBatch(ls, 2)
+	if err := animation.SetIn(batch, toInt(ls, 3), extractFloatArray(ls, 4)...); err != nil {
+		if batch.GetTexture() != animation.GetTexture() {
+			ls.ArgError(2, err.Error())
+		}
+		ls.ArgError(3, err.Error())
+	}
+	return 0
+}
+
+func gfxAnimationSetMode(ls *lua.LState) int {
+	toAnimation(ls, 1).SetMode(toAnimationMode(ls, 2))
+	return 0
+}
+
+func gfxAnimationGetMode(ls *lua.LState) int {
+	ls.Push(lua.LString(fromAnimationMode(toAnimation(ls, 1).GetMode())))
+	return 1
+}
+
+// gfxAnimationAddTag takes a name, the first and last frame and optionally the
+// mode of the tag and if it plays in reverse.
+func gfxAnimationAddTag(ls *lua.LState) int {
+	tag := gfx.AnimationTag{
+		From:    toInt(ls, 3),
+		To:      toInt(ls, 4),
+		Mode:    toAnimationMode(ls, 5),
+		Reverse: ls.ToBool(6),
+	}
+	if err := toAnimation(ls, 1).AddTag(toString(ls, 2), tag); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxAnimationSetTag(ls *lua.LState) int {
+	if err := toAnimation(ls, 1).SetTag(toStringD(ls, 2, "")); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxAnimationGetTag(ls *lua.LState) int {
+	ls.Push(lua.LString(toAnimation(ls, 1).GetTag()))
+	return 1
+}
+
+func gfxAnimationGetTags(ls *lua.LState) int {
+	tags := ls.NewTable()
+	for _, name := range toAnimation(ls, 1).GetTags() {
+		tags.Append(lua.LString(name))
+	}
+	ls.Push(tags)
+	return 1
+}
+
+func gfxAnimationSetFlip(ls *lua.LState) int {
+	toAnimation(ls, 1).SetFlip(ls.ToBool(2), ls.ToBool(3))
+	return 0
+}
+
+func gfxAnimationGetFlip(ls *lua.LState) int {
+	x, y := toAnimation(ls, 1).GetFlip()
+	ls.Push(lua.LBool(x))
+	ls.Push(lua.LBool(y))
+	return 2
+}
+
+func gfxAnimationGotoFrame(ls *lua.LState) int {
+	if err := toAnimation(ls, 1).GotoFrame(toInt(ls, 2)); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxAnimationGetFrame(ls *lua.LState) int {
+	ls.Push(lua.LNumber(toAnimation(ls, 1).GetFrame()))
+	return 1
+}
+
+func gfxAnimationGetFrameCount(ls *lua.LState) int {
+	ls.Push(lua.LNumber(toAnimation(ls, 1).GetFrameCount()))
+	return 1
+}
+
+func gfxAnimationSetDuration(ls *lua.LState) int {
+	if err := toAnimation(ls, 1).SetDuration(toInt(ls, 2), toFloat(ls, 3)); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxAnimationGetDuration(ls *lua.LState) int {
+	duration, err := toAnimation(ls, 1).GetDuration(toInt(ls, 2))
+	if err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	ls.Push(lua.LNumber(duration))
+	return 1
+}
+
+func gfxAnimationPause(ls *lua.LState) int {
+	toAnimation(ls, 1).Pause()
+	return 0
+}
+
+func gfxAnimationResume(ls *lua.LState) int {
+	toAnimation(ls, 1).Resume()
+	return 0
+}
+
+func gfxAnimationRestart(ls *lua.LState) int {
+	toAnimation(ls, 1).Restart()
+	return 0
+}
+
+func gfxAnimationIsPlaying(ls *lua.LState) int {
+	ls.Push(lua.LBool(toAnimation(ls, 1).IsPlaying()))
+	return 1
+}
+
+func gfxAnimationGetQuad(ls *lua.LState) int {
+	return returnUD(ls, "Quad", toAnimation(ls, 1).GetQuad())
+}
+
+func gfxAnimationGetDimensions(ls *lua.LState) int {
+	w, h := toAnimation(ls, 1).GetDimensions()
+	ls.Push(lua.LNumber(w))
+	ls.Push(lua.LNumber(h))
+	return 2
+}
+
+// gfxAnimationOnFrame sets a function that is called with the frame index every
+// time update changes the frame. Passing nil removes it.
+func gfxAnimationOnFrame(ls *lua.LState) int {
+	animation := toAnimation(ls, 1)
+	if ls.Get(2) == lua.LNil {
+		animation.SetFrameCallback(nil)
+		return 0
+	}
+	animation.SetFrameCallback(ls.CheckFunction(2))
+	return 0
+}
+
+// gfxAnimationOnLoop sets a function that is called every time update reaches the
+// end of the frames being played. Passing nil removes it.
+func gfxAnimationOnLoop(ls *lua.LState) int {
+	animation := toAnimation(ls, 1)
+	if ls.Get(2) == lua.LNil {
+		animation.SetLoopCallback(nil)
+		return 0
+	}
+	animation.SetLoopCallback(ls.CheckFunction(2))
+	return 0
+}
+
+// raiseCallbackError will rethrow the error of a lua callback called from go so
+// that it reaches lua as the original error value.
+func raiseCallbackError(ls *lua.LState, err error) {
+	if apiErr, ok := err.(*lua.ApiError); ok {
+		ls.Error(apiErr.Object, 0)
+	}
+	ls.RaiseError("%v", err)
+}
+
+// toLoadedTexture is toTextureSource for functions that need the size of the texture
+func toLoadedTexture(ls *lua.LState, offset int) gfx.ITexture {
+	texture := toTextureSource(ls, offset)
+	if toTexture(ls, offset) == nil {
+		ls.ArgError(offset, "texture not loaded")
+	}
+	return texture
+}
+
+// extractDurations will read a duration for every frame from a number or a table
+// of numbers. No durations are returned if there is neither.
+func extractDurations(ls *lua.LState, offset int) []float32 {
+	switch lv := ls.Get(offset).(type) {
+	case lua.LNumber:
+		return []float32{float32(lv)}
+	case *lua.LTable:
+		durations := []float32{}
+		lv.ForEach(func(_, value lua.LValue) {
+			if number, ok := value.(lua.LNumber); ok {
+				durations = append(durations, float32(number))
+				return
+			}
+			ls.ArgError(offset, "table of durations expected")
+		})
+		return durations
+	}
+	return nil
+}
+
+func toAnimationMode(ls *lua.LState, offset int) gfx.AnimationMode {
+	switch toStringD(ls, offset, "loop") {
+	case "loop":
+		return gfx.AnimationLoop
+	case "pingpong":
+		return gfx.AnimationPingPong
+	case "once":
+		return gfx.AnimationOnce
+	default:
+		ls.ArgError(offset, "invalid animation mode")
+	}
+	return gfx.AnimationLoop
+}
+
+func fromAnimationMode(mode gfx.AnimationMode) string {
+	switch mode {
+	case gfx.AnimationPingPong:
+		return "pingpong"
+	case gfx.AnimationOnce:
+		return "once"
+	default:
+		return "loop"
+	}
+}
@@ -0,0 +1,106 @@
+package wrap
+
+import (
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/gfx"
+)
+
+func toAtlas(ls *lua.LState, offset int) *gfx.Atlas {
+	atlas := ls.CheckUserData(offset)
+	if v, ok := atlas.Value.(*gfx.Atlas); ok {
+		return v
+	}
+	ls.ArgError(offset, "atlas expected")
+	return nil
+}
+
+// gfxNewAtlas takes a path or a table of paths and an optional table of options
+// with size, padding, extrude, mipmaps and cache. It returns nil and an error if
+// the images could not be packed.
+func gfxNewAtlas(ls *lua.LState) int {
+	paths := []string{}
+	switch lv := ls.Get(1).(type) {
+	case lua.LString:
+		paths = append(paths, string(lv))
+	case *lua.LTable:
+		lv.ForEach(func(_, value lua.LValue) {
+			paths = append(paths, value.String())
+		})
+	default:
+		ls.ArgError(1, "path or table of paths expected")
+	}
+
+	options := gfx.AtlasOptions{}
+	if table, ok := ls.Get(2).(*lua.LTable); ok {
+		if lv, ok := table.RawGetString("size").(lua.LNumber); ok {
+			options.Size = int32(lv)
+		}
+		if lv, ok := table.RawGetString("padding").(lua.LNumber); ok {
+			options.Padding = int32(lv)
+		}
+		if lv, ok := table.RawGetString("extrude").(lua.LNumber); ok {
+			options.Extrude = int32(lv)
+		}
+		if lv, ok := table.RawGetString("mipmaps").(lua.LBool); ok {
+			options.Mipmaps = bool(lv)
+		}
+		if lv, ok := table.RawGetString("cache").(lua.LString); ok {
+			options.Cache = string(lv)
+		}
+	}
+
+	atlas, err := gfx.NewAtlas(paths, options)
+	if err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	return returnUD(ls, "Atlas", atlas)
+}
+
+func gfxAtlasGetQuad(ls *lua.LState) int {
+	quad := toAtlas(ls, 1).GetQuad(toString(ls, 2))
+	if quad == nil {
+		ls.Push(lua.LNil)
+		return 1
+	}
+	return returnUD(ls, "Quad", quad)
+}
+
+func gfxAtlasGetImage(ls *lua.LState) int {
+	img := toAtlas(ls, 1).GetImage(toString(ls, 2))
+	if img == nil {
+		ls.Push(lua.LNil)
+		return 1
+	}
+	return returnUD(ls, "Image", img)
+}
+
+func gfxAtlasGetNames(ls *lua.LState) int {
+	names := ls.NewTable()
+	for _, name := range toAtlas(ls, 1).GetNames() {
+		names.Append(lua.LString(name))
+	}
+	ls.Push(names)
+	return 1
+}
+
+func gfxAtlasGetPages(ls *lua.LState) int {
+	pages := ls.NewTable()
+	for _, page := range toAtlas(ls, 1).GetPages() {
+		ud := ls.NewUserData()
+		ud.Value = page
+		ls.SetMetatable(ud, ls.GetTypeMetatable("Image"))
+		pages.Append(ud)
+	}
+	ls.Push(pages)
+	return 1
+}
+
+func gfxAtlasDraw(ls *lua.LState) int {
+	if err := toAtlas(ls, 1).Draw(toString(ls, 2), extractFloatArray(ls, 3)...); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
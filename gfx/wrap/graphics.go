@@ -4,6 +4,7 @@ import (
 	"github.com/yuin/gopher-lua"
 
 	"github.com/tanema/amore/gfx"
+	"github.com/tanema/amore/runtime"
 )
 
 func gfxCirle(ls *lua.LState) int {
@@ -12,7 +13,7 @@ func gfxCirle(ls *lua.LState) int {
 }
 
 func gfxArc(ls *lua.LState) int {
-	gfx.Arc(extractMode(ls, 1), toFloat(ls, 2), toFloat(ls, 3), toFloat(ls, 4), toFloat(ls, 5), toFloat(ls, 6), toIntD(ls, 6, 30))
+	gfx.Arc(extractMode(ls, 1), toFloat(ls, 2), toFloat(ls, 3), toFloat(ls, 4), toFloat(ls, 5), toFloat(ls, 6), toIntD(ls, 7, 30))
 	return 0
 }
 
@@ -259,11 +260,21 @@ func gfxSetBlendMode(ls *lua.LState) int {
 }
 
 func gfxGetCanvas(ls *lua.LState) int {
-	return returnUD(ls, "Canvas", gfx.GetCanvas())
+	canvas := gfx.GetCanvas()
+	if canvas == nil {
+		ls.Push(lua.LNil)
+		return 1
+	}
+	return returnUD(ls, "Canvas", canvas)
 }
 
+// gfxSetCanvas will draw to the canvas given or to the screen if there is none
 func gfxSetCanvas(ls *lua.LState) int {
-	gfx.SetCanvas(toCanvas(ls, 1))
+	if ls.Get(1) == lua.LNil {
+		gfx.SetCanvas(nil)
+	} else {
+		gfx.SetCanvas(toCanvas(ls, 1))
+	}
 	return 0
 }
 
@@ -290,13 +301,36 @@ func gfxStencil(ls *lua.LState) int {
 		ls.ArgError(1, "a function is required for a stencil")
 	}
 	fnWrap := func() {
-		ls.CallByParam(lua.P{Fn: fn, Protect: true})
+		if err := ls.CallByParam(lua.P{Fn: fn, Protect: true}); err != nil {
+			runtime.ReportError(err)
+		}
 	}
 	gfx.Stencil(fnWrap, toStencilAction(toStringD(ls, 2, "replace"), 2), int32(toIntD(ls, 3, 1)), ls.ToBool(4))
 	return 0
 }
 
 func gfxSetShader(ls *lua.LState) int {
-	gfx.SetShader(toShader(ls, 1))
+	if ls.Get(1) == lua.LNil {
+		gfx.SetShader(nil)
+	} else {
+		gfx.SetShader(toShader(ls, 1))
+	}
 	return 0
 }
+
+func gfxGetStats(ls *lua.LState) int {
+	stats := gfx.GetStats()
+	times := runtime.GetFrameStats()
+	table := ls.NewTable()
+	table.RawSetString("drawcalls", lua.LNumber(stats.DrawCalls))
+	table.RawSetString("texturebinds", lua.LNumber(stats.TextureBinds))
+	table.RawSetString("shaderswitches", lua.LNumber(stats.ShaderSwitches))
+	table.RawSetString("vertices", lua.LNumber(stats.Vertices))
+	table.RawSetString("canvasswitches", lua.LNumber(stats.CanvasSwitches))
+	table.RawSetString("updatetime", lua.LNumber(times.Update.Seconds()))
+	table.RawSetString("drawtime", lua.LNumber(times.Draw.Seconds()))
+	table.RawSetString("gccount", lua.LNumber(times.GCCount))
+	table.RawSetString("gcpause", lua.LNumber(times.GCPause.Seconds()))
+	ls.Push(table)
+	return 1
+}
@@ -0,0 +1,133 @@
+package wrap
+
+import (
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/gfx"
+)
+
+func toInstances(ls *lua.LState, offset int) *gfx.Instances {
+	instances := ls.CheckUserData(offset)
+	if v, ok := instances.Value.(*gfx.Instances); ok {
+		return v
+	}
+	ls.ArgError(offset, "instances expected")
+	return nil
+}
+
+func gfxNewInstances(ls *lua.LState) int {
+	return returnUD(ls, "Instances", gfx.NewInstances(toIntD(ls, 1, 1000), toUsage(ls, 2)))
+}
+
+func gfxInstancesAdd(ls *lua.LState) int {
+	if err := toInstances(ls, 1).Add(extractFloatArray(ls, 2)...); err != nil {
+		ls.ArgError(1, err.Error())
+	}
+	return 0
+}
+
+func gfxInstancesAddq(ls *lua.LState) int {
+	if err := toInstances(ls, 1).Addq(toQuad(ls, 2), extractFloatArray(ls, 3)...); err != nil {
+		ls.ArgError(1, err.Error())
+	}
+	return 0
+}
+
+func gfxInstancesSet(ls *lua.LState) int {
+	if err := toInstances(ls, 1).Set(toInt(ls, 2), extractFloatArray(ls, 3)...); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxInstancesSetq(ls *lua.LState) int {
+	if err := toInstances(ls, 1).Setq(toInt(ls, 2), toQuad(ls, 3), extractFloatArray(ls, 4)...); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxInstancesClear(ls *lua.LState) int {
+	toInstances(ls, 1).Clear()
+	return 0
+}
+
+func gfxInstancesSetColor(ls *lua.LState) int {
+	instances := toInstances(ls, 1)
+	if len(extractFloatArray(ls, 2)) == 0 {
+		instances.ClearColor()
+	} else {
+		r, g, b, a := extractColor(ls, 2)
+		instances.SetColor(r, g, b, a)
+	}
+	return 0
+}
+
+func gfxInstancesGetColor(ls *lua.LState) int {
+	for _, x := range toInstances(ls, 1).GetColor() {
+		ls.Push(lua.LNumber(x))
+	}
+	return 4
+}
+
+func gfxInstancesGetCount(ls *lua.LState) int {
+	ls.Push(lua.LNumber(toInstances(ls, 1).GetCount()))
+	return 1
+}
+
+func gfxInstancesSetBufferSize(ls *lua.LState) int {
+	if err := toInstances(ls, 1).SetBufferSize(toInt(ls, 2)); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxInstancesGetBufferSize(ls *lua.LState) int {
+	ls.Push(lua.LNumber(toInstances(ls, 1).GetBufferSize()))
+	return 1
+}
+
+func gfxHasInstancing(ls *lua.LState) int {
+	ls.Push(lua.LBool(gfx.HasInstancing()))
+	return 1
+}
+
+func gfxSetInstancing(ls *lua.LState) int {
+	gfx.SetInstancing(ls.ToBool(1))
+	return 0
+}
+
+// toInstancesOrNil will return the instances at offset or nil if the argument is nil
+// so that instances can be detached.
+func toInstancesOrNil(ls *lua.LState, offset int) *gfx.Instances {
+	if ls.Get(offset) == lua.LNil {
+		return nil
+	}
+	return toInstances(ls, offset)
+}
+
+func returnInstances(ls *lua.LState, instances *gfx.Instances) int {
+	if instances == nil {
+		ls.Push(lua.LNil)
+		return 1
+	}
+	return returnUD(ls, "Instances", instances)
+}
+
+func gfxMeshSetInstances(ls *lua.LState) int {
+	toMesh(ls, 1).SetInstances(toInstancesOrNil(ls, 2))
+	return 0
+}
+
+func gfxMeshGetInstances(ls *lua.LState) int {
+	return returnInstances(ls, toMesh(ls, 1).GetInstances())
+}
+
+func gfxSpriteBatchSetInstances(ls *lua.LState) int {
+	toSpriteBatch(ls, 1).SetInstances(toInstancesOrNil(ls, 2))
+	return 0
+}
+
+func gfxSpriteBatchGetInstances(ls *lua.LState) int {
+	return returnInstances(ls, toSpriteBatch(ls, 1).GetInstances())
+}
@@ -0,0 +1,268 @@
+package wrap
+
+import (
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/gfx"
+)
+
+func toMesh(ls *lua.LState, offset int) *gfx.Mesh {
+	mesh := ls.CheckUserData(offset)
+	if v, ok := mesh.Value.(*gfx.Mesh); ok {
+		return v
+	}
+	ls.ArgError(offset, "mesh expected")
+	return nil
+}
+
+// gfxNewMesh creates a mesh from a table of vertices, each a table of attribute
+// values, or a count of vertices that all start at 0. The format is an optional
+// table of {name, components} attributes.
+func gfxNewMesh(ls *lua.LState) int {
+	format := extractVertexFormat(ls, 4)
+	stride := 0
+	for _, attrib := range format {
+		stride += attrib.Components
+	}
+
+	var vertices []float32
+	if count, ok := ls.Get(1).(lua.LNumber); ok {
+		vertices = make([]float32, int(count)*stride)
+	} else {
+		vertices = extractVertices(ls, 1, stride)
+	}
+
+	mesh, err := gfx.NewMesh(format, vertices, toMeshDrawMode(ls, 2), toUsage(ls, 3))
+	if err != nil {
+		ls.ArgError(1, err.Error())
+	}
+	return returnUD(ls, "Mesh", mesh)
+}
+
+func gfxMeshSetVertex(ls *lua.LState) int {
+	var values []float32
+	if table, ok := ls.Get(3).(*lua.LTable); ok {
+		values = tableToFloats(ls, table, 3)
+	} else {
+		values = extractFloatArray(ls, 3)
+	}
+	if err := toMesh(ls, 1).SetVertex(toInt(ls, 2), values); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxMeshGetVertex(ls *lua.LState) int {
+	values, err := toMesh(ls, 1).GetVertex(toInt(ls, 2))
+	if err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	for _, value := range values {
+		ls.Push(lua.LNumber(value))
+	}
+	return len(values)
+}
+
+func gfxMeshSetVertices(ls *lua.LState) int {
+	mesh := toMesh(ls, 1)
+	stride := 0
+	for _, attrib := range mesh.GetVertexFormat() {
+		stride += attrib.Components
+	}
+	if err := mesh.SetVertices(toIntD(ls, 3, 0), extractVertices(ls, 2, stride)); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxMeshGetVertexCount(ls *lua.LState) int {
+	ls.Push(lua.LNumber(toMesh(ls, 1).GetVertexCount()))
+	return 1
+}
+
+func gfxMeshGetVertexFormat(ls *lua.LState) int {
+	format := ls.NewTable()
+	for _, attrib := range toMesh(ls, 1).GetVertexFormat() {
+		attribTable := ls.NewTable()
+		attribTable.Append(lua.LString(attrib.Name))
+		attribTable.Append(lua.LNumber(attrib.Components))
+		format.Append(attribTable)
+	}
+	ls.Push(format)
+	return 1
+}
+
+func gfxMeshSetVertexMap(ls *lua.LState) int {
+	mesh := toMesh(ls, 1)
+	var indices []int32
+	if table, ok := ls.Get(2).(*lua.LTable); ok {
+		for _, value := range tableToFloats(ls, table, 2) {
+			indices = append(indices, int32(value))
+		}
+	} else {
+		indices = extractIntArray(ls, 2)
+	}
+	if len(indices) == 0 {
+		mesh.ClearVertexMap()
+		return 0
+	}
+	vertexMap := make([]uint32, len(indices))
+	for i, index := range indices {
+		if index < 0 {
+			ls.ArgError(2, "invalid vertex index in vertex map")
+		}
+		vertexMap[i] = uint32(index)
+	}
+	if err := mesh.SetVertexMap(vertexMap); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxMeshGetVertexMap(ls *lua.LState) int {
+	vertexMap := toMesh(ls, 1).GetVertexMap()
+	if vertexMap == nil {
+		ls.Push(lua.LNil)
+		return 1
+	}
+	table := ls.NewTable()
+	for _, index := range vertexMap {
+		table.Append(lua.LNumber(index))
+	}
+	ls.Push(table)
+	return 1
+}
+
+func gfxMeshSetTexture(ls *lua.LState) int {
+	mesh := toMesh(ls, 1)
+	if ls.Get(2) == lua.LNil {
+		mesh.SetTexture(nil)
+	} else {
+		mesh.SetTexture(toTextureSource(ls, 2))
+	}
+	return 0
+}
+
+func gfxMeshGetTexture(ls *lua.LState) int {
+	texture := toMesh(ls, 1).GetTexture()
+	if texture == nil {
+		ls.Push(lua.LNil)
+		return 1
+	}
+	return returnTexture(ls, texture)
+}
+
+func gfxMeshSetDrawMode(ls *lua.LState) int {
+	toMesh(ls, 1).SetDrawMode(toMeshDrawMode(ls, 2))
+	return 0
+}
+
+func gfxMeshGetDrawMode(ls *lua.LState) int {
+	ls.Push(lua.LString(fromMeshDrawMode(toMesh(ls, 1).GetDrawMode())))
+	return 1
+}
+
+func gfxMeshSetDrawRange(ls *lua.LState) int {
+	mesh := toMesh(ls, 1)
+	if ls.Get(2) == lua.LNil {
+		mesh.ClearDrawRange()
+	} else if err := mesh.SetDrawRange(toInt(ls, 2), toInt(ls, 3)); err != nil {
+		ls.ArgError(2, err.Error())
+	}
+	return 0
+}
+
+func gfxMeshGetDrawRange(ls *lua.LState) int {
+	min, max := toMesh(ls, 1).GetDrawRange()
+	ls.Push(lua.LNumber(min))
+	ls.Push(lua.LNumber(max))
+	return 2
+}
+
+func gfxMeshDraw(ls *lua.LState) int {
+	toMesh(ls, 1).Draw(extractFloatArray(ls, 2)...)
+	return 0
+}
+
+func toMeshDrawMode(ls *lua.LState, offset int) gfx.MeshDrawMode {
+	switch toStringD(ls, offset, "fan") {
+	case "fan":
+		return gfx.MeshFan
+	case "strip":
+		return gfx.MeshStrip
+	case "triangles":
+		return gfx.MeshTriangles
+	case "points":
+		return gfx.MeshPoints
+	default:
+		ls.ArgError(offset, "invalid mesh draw mode")
+	}
+	return gfx.MeshFan
+}
+
+func fromMeshDrawMode(mode gfx.MeshDrawMode) string {
+	switch mode {
+	case gfx.MeshStrip:
+		return "strip"
+	case gfx.MeshTriangles:
+		return "triangles"
+	case gfx.MeshPoints:
+		return "points"
+	default:
+		return "fan"
+	}
+}
+
+// extractVertexFormat will read a table of {name, components} attributes. If there
+// is no table the default vertex format is returned.
+func extractVertexFormat(ls *lua.LState, offset int) []gfx.VertexAttribute {
+	table, ok := ls.Get(offset).(*lua.LTable)
+	if !ok {
+		return gfx.DefaultVertexFormat
+	}
+	format := []gfx.VertexAttribute{}
+	table.ForEach(func(_, value lua.LValue) {
+		attrib, ok := value.(*lua.LTable)
+		if !ok {
+			ls.ArgError(offset, "vertex format attributes should be {name, components}")
+		}
+		name, nameOk := attrib.RawGetInt(1).(lua.LString)
+		components, componentsOk := attrib.RawGetInt(2).(lua.LNumber)
+		if !nameOk || !componentsOk {
+			ls.ArgError(offset, "vertex format attributes should be {name, components}")
+		}
+		format = append(format, gfx.VertexAttribute{Name: string(name), Components: int(components)})
+	})
+	return format
+}
+
+// extractVertices will read a table of vertices, each a table of attribute values,
+// into a flat array. Missing values in a vertex are left as 0.
+func extractVertices(ls *lua.LState, offset, stride int) []float32 {
+	table := ls.CheckTable(offset)
+	vertices := make([]float32, table.Len()*stride)
+	for i := 1; i <= table.Len(); i++ {
+		vertex, ok := table.RawGetInt(i).(*lua.LTable)
+		if !ok {
+			ls.ArgError(offset, "vertices should be tables of values")
+		}
+		values := tableToFloats(ls, vertex, offset)
+		if len(values) > stride {
+			values = values[:stride]
+		}
+		copy(vertices[(i-1)*stride:], values)
+	}
+	return vertices
+}
+
+func tableToFloats(ls *lua.LState, table *lua.LTable, offset int) []float32 {
+	values := make([]float32, table.Len())
+	for i := range values {
+		value, ok := table.RawGetInt(i + 1).(lua.LNumber)
+		if !ok {
+			ls.ArgError(offset, "argument wrong type, should be number")
+		}
+		values[i] = float32(value)
+	}
+	return values
+}
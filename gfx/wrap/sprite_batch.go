@@ -19,7 +19,7 @@ func gfxNewSpriteBatch(ls *lua.LState) int {
 	return returnUD(
 		ls,
 		"SpriteBatch",
-		gfx.NewSpriteBatch(toTexture(ls, 1), toIntD(ls, 2, 1000), toUsage(ls, 3)),
+		gfx.NewSpriteBatch(toTextureSource(ls, 1), toIntD(ls, 2, 1000), toUsage(ls, 3)),
 	)
 }
 
@@ -49,12 +49,12 @@ func gfxSpriteBatchClear(ls *lua.LState) int {
 }
 
 func gfxSpriteBatchSetTexture(ls *lua.LState) int {
-	toSpriteBatch(ls, 1).SetTexture(toTexture(ls, 2))
+	toSpriteBatch(ls, 1).SetTexture(toTextureSource(ls, 2))
 	return 0
 }
 
 func gfxSpriteBatchGetTexture(ls *lua.LState) int {
-	return returnUD(ls, "Image", toSpriteBatch(ls, 1).GetTexture())
+	return returnTexture(ls, toSpriteBatch(ls, 1).GetTexture())
 }
 
 func gfxSpriteBatchSetColor(ls *lua.LState) int {
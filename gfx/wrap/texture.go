@@ -40,6 +40,30 @@ func toTexture(ls *lua.LState, offset int) *gfx.Texture {
 	return nil
 }
 
+// toTextureSource will return the image or canvas itself rather than its texture
+// so that objects that keep it, like sprite batches, stop it from being garbage
+// collected, which would delete the texture, and draw the new texture when it
+// is reloaded.
+func toTextureSource(ls *lua.LState, offset int) gfx.ITexture {
+	text := ls.CheckUserData(offset)
+	switch v := text.Value.(type) {
+	case *gfx.Canvas:
+		return v
+	case *gfx.Image:
+		return v
+	}
+	ls.ArgError(offset, "texture expected")
+	return nil
+}
+
+// returnTexture will push a texture source with the metatable of its type
+func returnTexture(ls *lua.LState, texture gfx.ITexture) int {
+	if canvas, ok := texture.(*gfx.Canvas); ok {
+		return returnUD(ls, "Canvas", canvas)
+	}
+	return returnUD(ls, "Image", texture)
+}
+
 func gfxNewImage(ls *lua.LState) int {
 	return returnUD(ls, "Image", gfx.NewImage(toString(ls, 1), ls.ToBool(2)))
 }
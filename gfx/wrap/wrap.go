@@ -1,6 +1,11 @@
 package wrap
 
-import "github.com/tanema/amore/runtime"
+import (
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/gfx"
+	"github.com/tanema/amore/runtime"
+)
 
 var graphicsFunctions = runtime.LuaFuncs{
 	"circle":             gfxCirle,
@@ -42,19 +47,30 @@ var graphicsFunctions = runtime.LuaFuncs{
 	"getfont":            gfxGetFont,
 	"setfont":            gfxSetFont,
 	"setblendmode":       gfxSetBlendMode,
+	"setcanvas":          gfxSetCanvas,
+	"getcanvas":          gfxGetCanvas,
 	"getstenciltest":     gfxGetStencilTest,
 	"setstenciltest":     gfxSetStencilTest,
 	"stencil":            gfxStencil,
 	"setshader":          gfxSetShader,
+	"getstats":           gfxGetStats,
+	"hasinstancing":      gfxHasInstancing,
+	"setinstancing":      gfxSetInstancing,
 
 	// metatable entries
-	"newimage":       gfxNewImage,
-	"newtext":        gfxNewText,
-	"newfont":        gfxNewFont,
-	"newquad":        gfxNewQuad,
-	"newcanvas":      gfxNewCanvas,
-	"newspritebatch": gfxNewSpriteBatch,
-	"newshader":      gfxNewShader,
+	"newimage":             gfxNewImage,
+	"newtext":              gfxNewText,
+	"newfont":              gfxNewFont,
+	"newquad":              gfxNewQuad,
+	"newcanvas":            gfxNewCanvas,
+	"newspritebatch":       gfxNewSpriteBatch,
+	"newshader":            gfxNewShader,
+	"newmesh":              gfxNewMesh,
+	"newinstances":         gfxNewInstances,
+	"newatlas":             gfxNewAtlas,
+	"newanimation":         gfxNewAnimation,
+	"newgridanimation":     gfxNewGridAnimation,
+	"newanimationfromjson": gfxNewAnimationFromJSON,
 }
 
 var graphicsMetaTables = runtime.LuaMetaTable{
@@ -113,13 +129,114 @@ var graphicsMetaTables = runtime.LuaMetaTable{
 		"getbuffersize": gfxSpriteBatchGetBufferSize,
 		"setdrawrange":  gfxSpriteBatchSetDrawRange,
 		"getdrawrange":  gfxSpriteBatchGetDrawRange,
+		"setinstances":  gfxSpriteBatchSetInstances,
+		"getinstances":  gfxSpriteBatchGetInstances,
 		"draw":          gfxSpriteBatchDraw,
 	},
 	"Shader": {
 		"send": gfxShaderSend,
 	},
+	"Mesh": {
+		"setvertex":       gfxMeshSetVertex,
+		"getvertex":       gfxMeshGetVertex,
+		"setvertices":     gfxMeshSetVertices,
+		"getvertexcount":  gfxMeshGetVertexCount,
+		"getvertexformat": gfxMeshGetVertexFormat,
+		"setvertexmap":    gfxMeshSetVertexMap,
+		"getvertexmap":    gfxMeshGetVertexMap,
+		"settexture":      gfxMeshSetTexture,
+		"gettexture":      gfxMeshGetTexture,
+		"setdrawmode":     gfxMeshSetDrawMode,
+		"getdrawmode":     gfxMeshGetDrawMode,
+		"setdrawrange":    gfxMeshSetDrawRange,
+		"getdrawrange":    gfxMeshGetDrawRange,
+		"setinstances":    gfxMeshSetInstances,
+		"getinstances":    gfxMeshGetInstances,
+		"draw":            gfxMeshDraw,
+	},
+	"Atlas": {
+		"getquad":  gfxAtlasGetQuad,
+		"getimage": gfxAtlasGetImage,
+		"getnames": gfxAtlasGetNames,
+		"getpages": gfxAtlasGetPages,
+		"draw":     gfxAtlasDraw,
+	},
+	"Animation": {
+		"update":        gfxAnimationUpdate,
+		"draw":          gfxAnimationDraw,
+		"addto":         gfxAnimationAddTo,
+		"setin":         gfxAnimationSetIn,
+		"setmode":       gfxAnimationSetMode,
+		"getmode":       gfxAnimationGetMode,
+		"addtag":        gfxAnimationAddTag,
+		"settag":        gfxAnimationSetTag,
+		"gettag":        gfxAnimationGetTag,
+		"gettags":       gfxAnimationGetTags,
+		"setflip":       gfxAnimationSetFlip,
+		"getflip":       gfxAnimationGetFlip,
+		"gotoframe":     gfxAnimationGotoFrame,
+		"getframe":      gfxAnimationGetFrame,
+		"getframecount": gfxAnimationGetFrameCount,
+		"setduration":   gfxAnimationSetDuration,
+		"getduration":   gfxAnimationGetDuration,
+		"pause":         gfxAnimationPause,
+		"resume":        gfxAnimationResume,
+		"restart":       gfxAnimationRestart,
+		"isplaying":     gfxAnimationIsPlaying,
+		"getquad":       gfxAnimationGetQuad,
+		"getdimensions": gfxAnimationGetDimensions,
+		"onframe":       gfxAnimationOnFrame,
+		"onloop":        gfxAnimationOnLoop,
+	},
+	"Instances": {
+		"add":           gfxInstancesAdd,
+		"addq":          gfxInstancesAddq,
+		"set":           gfxInstancesSet,
+		"setq":          gfxInstancesSetq,
+		"clear":         gfxInstancesClear,
+		"setcolor":      gfxInstancesSetColor,
+		"getcolor":      gfxInstancesGetColor,
+		"getcount":      gfxInstancesGetCount,
+		"setbuffersize": gfxInstancesSetBufferSize,
+		"getbuffersize": gfxInstancesGetBufferSize,
+	},
+}
+
+// contextFunctions are the functions, by module or metatable, that need a gl
+// context. They do nothing when running headless.
+var contextFunctions = map[string][]string{
+	"gfx": {
+		"circle", "arc", "ellipse", "points", "line", "rectangle", "polygon",
+		"setviewport", "clear", "print", "printf", "stencil", "setinstancing",
+	},
+	"Image":       {"draw", "drawq", "setwrap", "setfilter"},
+	"Canvas":      {"newimage", "draw", "drawq", "setwrap", "setfilter"},
+	"Text":        {"draw"},
+	"SpriteBatch": {"draw"},
+	"Shader":      {"send"},
+	"Mesh":        {"draw"},
+	"Atlas":       {"draw"},
+	"Animation":   {"draw"},
 }
 
 func init() {
+	for table, names := range contextFunctions {
+		funcs := graphicsFunctions
+		if table != "gfx" {
+			funcs = graphicsMetaTables[table]
+		}
+		for _, name := range names {
+			funcs[name] = needsContext(funcs[name])
+		}
+	}
 	runtime.RegisterModule("gfx", graphicsFunctions, graphicsMetaTables)
 }
+
+func needsContext(fn lua.LGFunction) lua.LGFunction {
+	return func(ls *lua.LState) int {
+		if gfx.IsHeadless() {
+			return 0
+		}
+		return fn(ls)
+	}
+}
@@ -0,0 +1,215 @@
+package input
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/file"
+	"github.com/tanema/amore/runtime"
+)
+
+type (
+	// Binding binds a device input to an action. If Button is set then it is a
+	// single button or gamepad axis. If Negative and Positive are set then it
+	// is an axis composed of two buttons.
+	Binding struct {
+		Device   string `json:"device"`
+		Button   string `json:"button,omitempty"`
+		Negative string `json:"negative,omitempty"`
+		Positive string `json:"positive,omitempty"`
+	}
+	// inputAction is a named input that can be bound to many device inputs
+	inputAction struct {
+		bindings []Binding
+		value    float32
+		down     bool
+	}
+)
+
+// actionThreshold is how far an action value has to be from 0 to be considered
+// down.
+const actionThreshold = 0.5
+
+var boundActions = map[string]*inputAction{}
+
+// Bind will replace all the bindings of the action with the bindings given. If
+// the action did not exist it will be created. A held action is released before
+// its bindings are replaced and pressed again if the new bindings hold it.
+func Bind(name string, bindings ...Binding) {
+	if act, ok := boundActions[name]; ok {
+		currentCapture.releaseAction(name, act)
+		act.bindings = bindings
+	} else {
+		boundActions[name] = &inputAction{bindings: bindings}
+	}
+	currentCapture.updateActions()
+}
+
+// AddBinding will add another binding to the action
+func AddBinding(name string, binding Binding) {
+	if act, ok := boundActions[name]; ok {
+		act.bindings = append(act.bindings, binding)
+		currentCapture.updateActions()
+	} else {
+		Bind(name, binding)
+	}
+}
+
+// Unbind will remove the action and all of its bindings, releasing it if it is held.
+func Unbind(name string) {
+	if act, ok := boundActions[name]; ok {
+		currentCapture.releaseAction(name, act)
+		delete(boundActions, name)
+	}
+}
+
+// GetBindings returns all the bindings for an action.
+func GetBindings(name string) []Binding {
+	if act, ok := boundActions[name]; ok {
+		return act.bindings
+	}
+	return nil
+}
+
+// GetActions returns the names of all the bound actions.
+func GetActions() []string {
+	names := []string{}
+	for name := range boundActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetActionValue returns the current value of the action. Buttons are either
+// 0 or 1 and axes range from -1 to 1.
+func GetActionValue(name string) float32 {
+	if act, ok := boundActions[name]; ok {
+		return act.value
+	}
+	return 0
+}
+
+// SaveBindings will write all the current bindings to a file in the save directory
+// as json so that they can be loaded again with LoadBindings.
+func SaveBindings(path string) error {
+	bindings := map[string][]Binding{}
+	for name, act := range boundActions {
+		bindings[name] = act.bindings
+	}
+	data, err := json.MarshalIndent(bindings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return file.Write(path, data)
+}
+
+// LoadBindings will load a bindings file written with SaveBindings. Actions in
+// the file will replace existing actions of the same name.
+func LoadBindings(path string) error {
+	data, err := file.Read(path)
+	if err != nil {
+		return err
+	}
+	bindings := map[string][]Binding{}
+	if err := json.Unmarshal(data, &bindings); err != nil {
+		return err
+	}
+	for name, binds := range bindings {
+		Bind(name, binds...)
+	}
+	return nil
+}
+
+// isAxis will return true if the binding is bound to an analog axis
+func (binding Binding) isAxis() bool {
+	return binding.Negative != "" || binding.Positive != "" ||
+		(binding.Device == "gamepad" && (isGamepadAxis(binding.Button) || strings.HasPrefix(binding.Button, "axis")))
+}
+
+// read will return the current value of the binding
+func (binding Binding) read(input *inputCapture) float32 {
+	if binding.Negative != "" || binding.Positive != "" {
+		var value float32
+		if input.isDown(binding.Device, binding.Positive) {
+			value++
+		}
+		if input.isDown(binding.Device, binding.Negative) {
+			value--
+		}
+		return value
+	} else if binding.isAxis() {
+		var value float32
+		for _, pad := range input.gamepads {
+			if axis := pad.axes[binding.Button]; abs(axis) > abs(value) {
+				value = axis
+			}
+		}
+		return value
+	} else if input.isDown(binding.Device, binding.Button) {
+		return 1
+	}
+	return 0
+}
+
+// updateActions is called after any input change and will recalculate the value
+// of every action, dispatching any changes to the onaction callback.
+func (input *inputCapture) updateActions() {
+	if input.ls == nil {
+		return
+	}
+	for _, name := range GetActions() {
+		act := boundActions[name]
+		var value float32
+		for _, binding := range act.bindings {
+			if bindValue := binding.read(input); abs(bindValue) > abs(value) {
+				value = bindValue
+			}
+		}
+		if value == act.value {
+			continue
+		}
+		wasDown := act.down
+		act.value, act.down = value, abs(value) >= actionThreshold
+		if act.down && !wasDown {
+			input.markFrame(input.pressed, "action", name)
+			input.dispatchAction(name, "press", value)
+		} else if !act.down && wasDown {
+			input.markFrame(input.released, "action", name)
+			input.dispatchAction(name, "release", value)
+		} else {
+			input.dispatchAction(name, "change", value)
+		}
+	}
+}
+
+// releaseAction will reset the action and dispatch a release with a value of 0 if
+// it was held, so that scripts that track it through onaction do not see it stuck.
+func (input *inputCapture) releaseAction(name string, act *inputAction) {
+	wasDown := act.down
+	act.value, act.down = 0, false
+	if wasDown && input.ls != nil {
+		input.markFrame(input.released, "action", name)
+		input.dispatchAction(name, "release", 0)
+	}
+}
+
+func (input *inputCapture) dispatchAction(name, event string, value float32) {
+	callback := input.ls.GetGlobal("onaction")
+	if callback == lua.LNil {
+		return
+	}
+	if err := input.ls.CallByParam(lua.P{Fn: callback, Protect: true}, lua.LString(name), lua.LString(event), lua.LNumber(value)); err != nil {
+		runtime.ReportError(err)
+	}
+}
+
+func abs(a float32) float32 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
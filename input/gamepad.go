@@ -0,0 +1,324 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+
+	"github.com/tanema/amore/file"
+	"github.com/tanema/amore/runtime"
+)
+
+type (
+	// gamepad is a connected joystick and its last known state
+	gamepad struct {
+		id      int
+		name    string
+		guid    string
+		mapping *gamepadMapping
+		buttons map[string]bool
+		axes    map[string]float32
+	}
+	// gamepadMapping maps the raw joystick inputs to named gamepad inputs, it is
+	// parsed from a SDL_GameControllerDB style mapping line.
+	gamepadMapping struct {
+		guid    string
+		name    string
+		buttons map[string]gamepadInput
+		axes    map[string]gamepadInput
+	}
+	// gamepadInput is a single raw joystick input
+	gamepadInput struct {
+		kind   byte // a for axis, b for button, h for hat
+		index  int
+		hatDir int
+		half   int // 1 or -1 if only half of the axis is used
+		invert bool
+	}
+)
+
+var (
+	gamepadMappings = map[string]*gamepadMapping{}
+	defaultDeadzone = float32(0.2)
+	axisDeadzones   = map[string]float32{}
+	mappingPlatform = map[string]string{
+		"linux":   "Linux",
+		"darwin":  "Mac OS X",
+		"windows": "Windows",
+	}[goruntime.GOOS]
+)
+
+// LoadMappings will load a SDL_GameControllerDB style mapping file. Mappings
+// for other platforms are skipped.
+func LoadMappings(path string) error {
+	data, err := file.Read(path)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := AddMapping(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// AddMapping will parse a single SDL_GameControllerDB mapping line and make it
+// available to connected and future gamepads. Desktop glfw does not report hats
+// so hat bindings like dpup:h0.1 only work with a backend that does.
+func AddMapping(line string) error {
+	fields := strings.Split(strings.TrimSuffix(strings.TrimSpace(line), ","), ",")
+	if len(fields) < 2 {
+		return fmt.Errorf("invalid gamepad mapping: %v", line)
+	}
+	mapping := &gamepadMapping{
+		guid:    fields[0],
+		name:    fields[1],
+		buttons: map[string]gamepadInput{},
+		axes:    map[string]gamepadInput{},
+	}
+	for _, field := range fields[2:] {
+		parts := strings.SplitN(field, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid gamepad mapping field %v in %v", field, mapping.name)
+		}
+		if parts[0] == "platform" {
+			if parts[1] != mappingPlatform {
+				return nil
+			}
+			continue
+		}
+		input, err := parseGamepadInput(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid gamepad mapping field %v in %v: %v", field, mapping.name, err)
+		}
+		if isGamepadAxis(parts[0]) {
+			mapping.axes[parts[0]] = input
+		} else {
+			mapping.buttons[parts[0]] = input
+		}
+	}
+	gamepadMappings[mapping.guid] = mapping
+	gamepadMappings[mapping.name] = mapping
+	for _, pad := range currentCapture.gamepads {
+		pad.mapping = findMapping(pad.guid, pad.name)
+	}
+	return nil
+}
+
+// usesHats will return true if any of the inputs of the mapping are read from a
+// hat. Desktop glfw does not report hats so these inputs never change there.
+func (mapping *gamepadMapping) usesHats() bool {
+	if mapping == nil {
+		return false
+	}
+	for _, in := range mapping.buttons {
+		if in.kind == 'h' {
+			return true
+		}
+	}
+	for _, in := range mapping.axes {
+		if in.kind == 'h' {
+			return true
+		}
+	}
+	return false
+}
+
+func isGamepadAxis(name string) bool {
+	switch name {
+	case "leftx", "lefty", "rightx", "righty", "lefttrigger", "righttrigger":
+		return true
+	}
+	return false
+}
+
+func parseGamepadInput(value string) (gamepadInput, error) {
+	input := gamepadInput{}
+	if strings.HasPrefix(value, "+") {
+		input.half, value = 1, value[1:]
+	} else if strings.HasPrefix(value, "-") {
+		input.half, value = -1, value[1:]
+	}
+	if strings.HasSuffix(value, "~") {
+		input.invert, value = true, value[:len(value)-1]
+	}
+	if len(value) < 2 {
+		return input, fmt.Errorf("input too short")
+	}
+	input.kind = value[0]
+	var err error
+	switch input.kind {
+	case 'a', 'b':
+		input.index, err = strconv.Atoi(value[1:])
+	case 'h':
+		hat := strings.SplitN(value[1:], ".", 2)
+		if len(hat) != 2 {
+			return input, fmt.Errorf("invalid hat")
+		}
+		if input.index, err = strconv.Atoi(hat[0]); err == nil {
+			input.hatDir, err = strconv.Atoi(hat[1])
+		}
+	default:
+		err = fmt.Errorf("unknown input type %v", string(input.kind))
+	}
+	return input, err
+}
+
+func findMapping(guid, name string) *gamepadMapping {
+	if mapping, ok := gamepadMappings[guid]; ok && guid != "" {
+		return mapping
+	}
+	return gamepadMappings[name]
+}
+
+// SetDeadzone will set the deadzone for the axis name, if the axis name is empty
+// it will set the default deadzone for all axes.
+func SetDeadzone(deadzone float32, axis string) {
+	if axis == "" {
+		defaultDeadzone = deadzone
+	} else {
+		axisDeadzones[axis] = deadzone
+	}
+}
+
+// applyDeadzone will zero out values within the deadzone and rescale the rest
+// so that the values still range from 0 to 1
+func applyDeadzone(axis string, value float32) float32 {
+	deadzone, ok := axisDeadzones[axis]
+	if !ok {
+		deadzone = defaultDeadzone
+	}
+	if deadzone >= 1 {
+		return 0
+	}
+	magnitude := value
+	if magnitude < 0 {
+		magnitude = -magnitude
+	}
+	if magnitude <= deadzone {
+		return 0
+	}
+	scaled := (magnitude - deadzone) / (1 - deadzone)
+	if value < 0 {
+		return -scaled
+	}
+	return scaled
+}
+
+// read will fetch the value of the input from raw joystick data
+func (in gamepadInput) read(axes []float32, buttons []bool, hats []int) float32 {
+	var value float32
+	switch in.kind {
+	case 'a':
+		if in.index < len(axes) {
+			value = axes[in.index]
+		}
+		if in.half > 0 && value < 0 || in.half < 0 && value > 0 {
+			value = 0
+		}
+	case 'b':
+		if in.index < len(buttons) && buttons[in.index] {
+			value = 1
+		}
+	case 'h':
+		if in.index < len(hats) && hats[in.index]&in.hatDir != 0 {
+			value = 1
+		}
+	}
+	if in.invert {
+		value = -value
+	}
+	return value
+}
+
+// pollGamepads will check for connected and disconnected joysticks and dispatch
+// any changes in gamepad state.
+func (input *inputCapture) pollGamepads() {
+	for id := 0; id < maxJoysticks; id++ {
+		pad, known := input.gamepads[id]
+		present := joysticks.Present(id)
+		if present && !known {
+			pad = &gamepad{
+				id:      id,
+				name:    joysticks.Name(id),
+				guid:    joysticks.GUID(id),
+				buttons: map[string]bool{},
+				axes:    map[string]float32{},
+			}
+			pad.mapping = findMapping(pad.guid, pad.name)
+			if pad.mapping.usesHats() && len(joysticks.Hats(id)) == 0 {
+				runtime.Warnf("gamepad %v reports no hats, its d-pad mapping will not work", pad.name)
+			}
+			input.gamepads[id] = pad
+			input.dispatch("gamepad", pad.name, "connect", nil, float64(id))
+		} else if !present && known {
+			for button, down := range pad.buttons {
+				if down {
+					input.gamepadButton(pad, button, false)
+				}
+			}
+			delete(input.gamepads, id)
+			input.dispatch("gamepad", pad.name, "disconnect", nil, float64(id))
+			continue
+		}
+		if present {
+			input.updateGamepad(pad, joysticks.Axes(id), joysticks.Buttons(id), joysticks.Hats(id))
+		}
+	}
+}
+
+func (input *inputCapture) updateGamepad(pad *gamepad, axes []float32, buttons []bool, hats []int) {
+	if pad.mapping == nil {
+		for i, down := range buttons {
+			input.gamepadButton(pad, fmt.Sprintf("button%v", i+1), down)
+		}
+		for i, value := range axes {
+			input.gamepadAxis(pad, fmt.Sprintf("axis%v", i+1), value)
+		}
+		return
+	}
+	for name, in := range pad.mapping.buttons {
+		input.gamepadButton(pad, name, in.read(axes, buttons, hats) > 0.5)
+	}
+	for name, in := range pad.mapping.axes {
+		input.gamepadAxis(pad, name, in.read(axes, buttons, hats))
+	}
+}
+
+func (input *inputCapture) gamepadButton(pad *gamepad, button string, down bool) {
+	if pad.buttons[button] == down {
+		return
+	}
+	pad.buttons[button] = down
+	if down {
+		input.markFrame(input.pressed, "gamepad", button)
+		input.dispatch("gamepad", button, "press", nil, float64(pad.id))
+	} else {
+		input.markFrame(input.released, "gamepad", button)
+		input.dispatch("gamepad", button, "release", nil, float64(pad.id))
+	}
+	input.updateActions()
+}
+
+func (input *inputCapture) gamepadAxis(pad *gamepad, axis string, value float32) {
+	input.setGamepadAxis(pad, axis, applyDeadzone(axis, value))
+}
+
+// setGamepadAxis will update the axis value after the deadzone has been applied
+func (input *inputCapture) setGamepadAxis(pad *gamepad, axis string, value float32) {
+	if pad.axes[axis] == value {
+		return
+	}
+	pad.axes[axis] = value
+	input.dispatch("gamepad", axis, "axis", nil, float64(pad.id), float64(value))
+	input.updateActions()
+}
@@ -15,28 +15,60 @@ type inputCapture struct {
 	scrolly        float64
 	mouseButtons   map[string]bool
 	keys           map[string]bool
+	pressed        map[string]map[string]bool // buttons pressed this frame by device
+	released       map[string]map[string]bool // buttons released this frame by device
+	repeated       map[string]map[string]bool // keys repeated this frame by device
+	gamepads       map[int]*gamepad
+	textInput      bool   // if char events should be dispatched to ontextinput
+	keyRepeat      bool   // if repeated key events should be dispatched to oninput
+	text           string // text entered this frame
 }
 
 var currentCapture inputCapture
 
 func init() {
+	runtime.RegisterModule("input", inputFunctions, nil)
+	runtime.RegisterFrameHook(func() { currentCapture.endFrame() })
+	runtime.RegisterEventHook(func(event runtime.Event) { currentCapture.replay(event) })
+	runtime.RegisterTestFunction("joystick", testJoystick)
+	runtime.RegisterSaveHook(func() func() {
+		saved := currentCapture
+		return func() { currentCapture = saved }
+	})
 	runtime.RegisterHook(func(ls *lua.LState, window *glfw.Window) {
 		currentCapture = inputCapture{
 			ls:           ls,
 			mouseButtons: map[string]bool{},
 			keys:         map[string]bool{},
+			pressed:      map[string]map[string]bool{},
+			released:     map[string]map[string]bool{},
+			repeated:     map[string]map[string]bool{},
+			gamepads:     map[int]*gamepad{},
+			textInput:    true,
+			keyRepeat:    true,
+		}
+		if testJoysticks != nil && joysticks == JoystickBackend(testJoysticks) {
+			joysticks = platformJoysticks{}
+		}
+		testJoysticks = nil
+		if runtime.Headless() {
+			return
 		}
 		window.SetCursorEnterCallback(currentCapture.mouseEnter)
 		window.SetMouseMovementCallback(currentCapture.mouseMove)
 		window.SetScrollCallback(currentCapture.mouseScroll)
 		window.SetMouseButtonCallback(currentCapture.mouseButton)
 		window.SetKeyCallback(currentCapture.key)
+		listenForText(window)
 	})
 }
 
-func (input *inputCapture) dispatch(device, button, action string, modifiers []string) {
+// dispatch will call the oninput callback with the event. Any extra values like
+// the gamepad id or axis value will be passed after the modifiers.
+func (input *inputCapture) dispatch(device, button, action string, modifiers []string, extra ...float64) {
+	runtime.RecordEvent(runtime.Event{Device: device, Button: button, Action: action, Modifiers: modifiers, Values: extra})
 	callback := input.ls.GetGlobal("oninput")
-	if callback == nil {
+	if callback == lua.LNil {
 		return
 	}
 
@@ -45,45 +77,194 @@ func (input *inputCapture) dispatch(device, button, action string, modifiers []s
 		luaModifiers.Append(lua.LString(mod))
 	}
 
-	input.ls.CallByParam(
-		lua.P{Fn: callback, Protect: true},
+	args := []lua.LValue{
 		lua.LString(device),
 		lua.LString(button),
 		lua.LString(action),
 		luaModifiers,
-	)
+	}
+	for _, value := range extra {
+		args = append(args, lua.LNumber(value))
+	}
+	if err := input.ls.CallByParam(lua.P{Fn: callback, Protect: true}, args...); err != nil {
+		runtime.ReportError(err)
+	}
+}
+
+// dispatchText will call the ontextinput callback with the text entered.
+func (input *inputCapture) dispatchText(text string) {
+	runtime.RecordEvent(runtime.Event{Device: "text", Button: text, Action: "input"})
+	callback := input.ls.GetGlobal("ontextinput")
+	if callback == lua.LNil {
+		return
+	}
+	if err := input.ls.CallByParam(lua.P{Fn: callback, Protect: true}, lua.LString(text)); err != nil {
+		runtime.ReportError(err)
+	}
+}
+
+// endFrame will reset all the per frame state like pressed and released buttons,
+// entered text and the scroll offset. Gamepads are then polled so that thier changes are
+// available in the next frame. Headless programs have no hardware so only a backend
+// set with SetJoystickBackend or test.joystick is polled.
+func (input *inputCapture) endFrame() {
+	input.scrollx, input.scrolly = 0, 0
+	input.pressed = map[string]map[string]bool{}
+	input.released = map[string]map[string]bool{}
+	input.repeated = map[string]map[string]bool{}
+	input.text = ""
+	if _, platform := joysticks.(platformJoysticks); input.ls != nil && (!runtime.Headless() || !platform) {
+		input.pollGamepads()
+	}
+}
+
+// isDown will return if the button on the device is currently held down
+func (input *inputCapture) isDown(device, button string) bool {
+	switch device {
+	case "keyboard":
+		return input.keys[button]
+	case "mouse":
+		return input.mouseButtons[button]
+	case "gamepad":
+		for _, pad := range input.gamepads {
+			if pad.buttons[button] {
+				return true
+			}
+		}
+	case "action":
+		if act, ok := boundActions[button]; ok {
+			return act.down
+		}
+	}
+	return false
+}
+
+// setButton will track the state of a button for polling
+func (input *inputCapture) setButton(device, button string, action glfw.Action, buttons map[string]bool) {
+	if action == glfw.Press {
+		buttons[button] = true
+		input.markFrame(input.pressed, device, button)
+	} else if action == glfw.Release {
+		buttons[button] = false
+		input.markFrame(input.released, device, button)
+	} else {
+		input.markFrame(input.repeated, device, button)
+		return
+	}
+	input.updateActions()
 }
 
-func (input *inputCapture) mouseEnter(w *glfw.Window, entered bool) { input.isInside = entered }
+func (input *inputCapture) markFrame(frame map[string]map[string]bool, device, button string) {
+	if _, ok := frame[device]; !ok {
+		frame[device] = map[string]bool{}
+	}
+	frame[device][button] = true
+}
+
+func (input *inputCapture) mouseEnter(w *glfw.Window, entered bool) {
+	input.isInside = entered
+	if entered {
+		runtime.RecordEvent(runtime.Event{Device: "mouse", Action: "enter"})
+	} else {
+		runtime.RecordEvent(runtime.Event{Device: "mouse", Action: "leave"})
+	}
+}
 
 func (input *inputCapture) mouseScroll(w *glfw.Window, xoff, yoff float64) {
-	input.scrollx, input.scrolly = xoff, yoff
+	input.scrollx += xoff
+	input.scrolly += yoff
+	runtime.RecordEvent(runtime.Event{Device: "mouse", Action: "scroll", Values: []float64{xoff, yoff}})
 }
 
 func (input *inputCapture) mouseMove(w *glfw.Window, xpos, ypos, xdelta, ydelta float64) {
 	input.mousex, input.mousey = xpos, ypos
+	runtime.RecordEvent(runtime.Event{Device: "mouse", Action: "move", Values: []float64{xpos, ypos}})
 }
 
 func (input *inputCapture) mouseButton(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
 	buttonName := mouseButtons[button]
-	if action == glfw.Press {
-		input.mouseButtons[buttonName] = true
-	} else if action == glfw.Release {
-		input.mouseButtons[buttonName] = false
-	}
+	input.setButton("mouse", buttonName, action, input.mouseButtons)
 	input.dispatch("mouse", buttonName, actions[action], expandModifiers(mods))
 }
 
 func (input *inputCapture) key(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
-	buttonName := keyboardMap[key]
-	if action == glfw.Press {
-		input.keys[buttonName] = true
-	} else if action == glfw.Release {
-		input.keys[buttonName] = false
+	if runtime.ConsoleKey(key, action, mods) || runtime.StatsKey(key, action) {
+		return
 	}
+	// repeats that are not dispatched are ignored entirely so that they are not
+	// marked as repeated without being recorded, which would break replays.
+	if action == glfw.Repeat && !input.keyRepeat {
+		return
+	}
+	buttonName := keyboardMap[key]
+	input.setButton("keyboard", buttonName, action, input.keys)
 	input.dispatch("keyboard", buttonName, actions[action], expandModifiers(mods))
 }
 
+// char receives the unicode characters produced by the keyboard layout, so
+// shifted, non-US and dead key characters arrive as the text the user intended
+// to type rather than the physical key. Text from an input method only arrives
+// once it is committed, glfw 3.2 has no preedit events so the composition is
+// drawn by the system and cannot be shown in game. In the browser the characters
+// come from keypress events, see listenForText.
+func (input *inputCapture) char(w *glfw.Window, char rune) {
+	if runtime.ConsoleText(char) || !input.textInput {
+		return
+	}
+	text := string(char)
+	input.text += text
+	input.dispatchText(text)
+}
+
+// replay will apply a recorded event to the capture state and dispatch it to the
+// lua state the same way it was when it was recorded.
+func (input *inputCapture) replay(event runtime.Event) {
+	switch event.Device {
+	case "keyboard", "mouse":
+		buttons := input.keys
+		if event.Device == "mouse" {
+			buttons = input.mouseButtons
+		}
+		switch event.Action {
+		case "enter", "leave":
+			input.isInside = event.Action == "enter"
+		case "scroll":
+			input.scrollx += event.Values[0]
+			input.scrolly += event.Values[1]
+		case "move":
+			input.mousex, input.mousey = event.Values[0], event.Values[1]
+		default:
+			for action, name := range actions {
+				if name == event.Action {
+					input.setButton(event.Device, event.Button, action, buttons)
+				}
+			}
+			input.dispatch(event.Device, event.Button, event.Action, event.Modifiers, event.Values...)
+		}
+	case "gamepad":
+		id := int(event.Values[0])
+		switch event.Action {
+		case "connect":
+			input.gamepads[id] = &gamepad{id: id, name: event.Button, buttons: map[string]bool{}, axes: map[string]float32{}}
+			input.dispatch(event.Device, event.Button, event.Action, event.Modifiers, event.Values...)
+		case "disconnect":
+			delete(input.gamepads, id)
+			input.dispatch(event.Device, event.Button, event.Action, event.Modifiers, event.Values...)
+		case "press", "release":
+			if pad, ok := input.gamepads[id]; ok {
+				input.gamepadButton(pad, event.Button, event.Action == "press")
+			}
+		case "axis":
+			if pad, ok := input.gamepads[id]; ok {
+				input.setGamepadAxis(pad, event.Button, float32(event.Values[1]))
+			}
+		}
+	case "text":
+		input.text += event.Button
+		input.dispatchText(event.Button)
+	}
+}
+
 func expandModifiers(keys glfw.ModifierKey) []string {
 	mods := []string{}
 	if keys&glfw.ModShift == glfw.ModShift {
@@ -0,0 +1,192 @@
+package input
+
+import (
+	"github.com/yuin/gopher-lua"
+)
+
+// maxJoysticks is the amount of joystick slots that are polled each frame.
+const maxJoysticks = 16
+
+// JoystickBackend is the source of raw joystick data. By default the platform
+// backend is used but it can be replaced, for instance with FakeJoysticks, to
+// drive gamepad input without any hardware.
+type JoystickBackend interface {
+	Present(id int) bool
+	Name(id int) string
+	GUID(id int) string
+	Axes(id int) []float32
+	Buttons(id int) []bool
+	// Hats returns the bitmask of each hat, 1 up, 2 right, 4 down and 8 left.
+	Hats(id int) []int
+}
+
+var (
+	joysticks JoystickBackend = platformJoysticks{}
+	// testJoysticks is the backend driven by test.joystick in the current test
+	testJoysticks *FakeJoysticks
+)
+
+// SetJoystickBackend will replace the source of joystick data. Any connected
+// gamepads will be disconnected on the next frame if they are not present in the
+// new backend.
+func SetJoystickBackend(backend JoystickBackend) {
+	joysticks = backend
+}
+
+// FakeJoysticks is a JoystickBackend that is driven by code instead of hardware.
+// This is useful for testing and scripting gamepad input.
+type FakeJoysticks struct {
+	joysticks map[int]*fakeJoystick
+}
+
+type fakeJoystick struct {
+	name, guid string
+	axes       []float32
+	buttons    []bool
+	hats       []int
+}
+
+// NewFakeJoysticks will create a new fake backend with no joysticks connected.
+func NewFakeJoysticks() *FakeJoysticks {
+	return &FakeJoysticks{joysticks: map[int]*fakeJoystick{}}
+}
+
+// Connect will plug in a joystick at the id with the amount of axes and buttons
+// specified.
+func (fake *FakeJoysticks) Connect(id int, name, guid string, axes, buttons, hats int) {
+	fake.joysticks[id] = &fakeJoystick{
+		name:    name,
+		guid:    guid,
+		axes:    make([]float32, axes),
+		buttons: make([]bool, buttons),
+		hats:    make([]int, hats),
+	}
+}
+
+// Disconnect will unplug the joystick at the id.
+func (fake *FakeJoysticks) Disconnect(id int) {
+	delete(fake.joysticks, id)
+}
+
+// SetAxis will set the raw value of an axis on the joystick.
+func (fake *FakeJoysticks) SetAxis(id, axis int, value float32) {
+	if joy, ok := fake.joysticks[id]; ok && axis >= 0 && axis < len(joy.axes) {
+		joy.axes[axis] = value
+	}
+}
+
+// SetButton will set the raw state of a button on the joystick.
+func (fake *FakeJoysticks) SetButton(id, button int, down bool) {
+	if joy, ok := fake.joysticks[id]; ok && button >= 0 && button < len(joy.buttons) {
+		joy.buttons[button] = down
+	}
+}
+
+// SetHat will set the direction bitmask of a hat on the joystick.
+func (fake *FakeJoysticks) SetHat(id, hat int, direction int) {
+	if joy, ok := fake.joysticks[id]; ok && hat >= 0 && hat < len(joy.hats) {
+		joy.hats[hat] = direction
+	}
+}
+
+// Present satisfies the JoystickBackend interface
+func (fake *FakeJoysticks) Present(id int) bool {
+	_, ok := fake.joysticks[id]
+	return ok
+}
+
+// Name satisfies the JoystickBackend interface
+func (fake *FakeJoysticks) Name(id int) string {
+	if joy, ok := fake.joysticks[id]; ok {
+		return joy.name
+	}
+	return ""
+}
+
+// GUID satisfies the JoystickBackend interface
+func (fake *FakeJoysticks) GUID(id int) string {
+	if joy, ok := fake.joysticks[id]; ok {
+		return joy.guid
+	}
+	return ""
+}
+
+// Axes satisfies the JoystickBackend interface
+func (fake *FakeJoysticks) Axes(id int) []float32 {
+	if joy, ok := fake.joysticks[id]; ok {
+		return joy.axes
+	}
+	return nil
+}
+
+// Buttons satisfies the JoystickBackend interface
+func (fake *FakeJoysticks) Buttons(id int) []bool {
+	if joy, ok := fake.joysticks[id]; ok {
+		return joy.buttons
+	}
+	return nil
+}
+
+// Hats satisfies the JoystickBackend interface
+func (fake *FakeJoysticks) Hats(id int) []int {
+	if joy, ok := fake.joysticks[id]; ok {
+		return joy.hats
+	}
+	return nil
+}
+
+// testJoystick sets the state of the fake joystick at the id from a table with a
+// name, guid and tables of axes, buttons and hats. Passing nil disconnects it.
+// The fake backend replaces the platform one for the rest of the test.
+func testJoystick(ls *lua.LState) int {
+	if testJoysticks == nil {
+		testJoysticks = NewFakeJoysticks()
+		SetJoystickBackend(testJoysticks)
+	}
+	id := ls.CheckInt(1)
+	if ls.Get(2) == lua.LNil {
+		testJoysticks.Disconnect(id)
+		return 0
+	}
+	state := ls.CheckTable(2)
+	axes := toNumbers(ls, state.RawGetString("axes"))
+	buttons := toNumbers(ls, state.RawGetString("buttons"))
+	hats := toNumbers(ls, state.RawGetString("hats"))
+	if joy, ok := testJoysticks.joysticks[id]; !ok || len(joy.axes) != len(axes) || len(joy.buttons) != len(buttons) || len(joy.hats) != len(hats) {
+		testJoysticks.Connect(id, lua.LVAsString(state.RawGetString("name")), lua.LVAsString(state.RawGetString("guid")), len(axes), len(buttons), len(hats))
+	}
+	for i, value := range axes {
+		testJoysticks.SetAxis(id, i, float32(value))
+	}
+	for i, value := range buttons {
+		testJoysticks.SetButton(id, i, value != 0)
+	}
+	for i, value := range hats {
+		testJoysticks.SetHat(id, i, int(value))
+	}
+	return 0
+}
+
+// toNumbers reads a table of numbers, true is read as 1 and false as 0
+func toNumbers(ls *lua.LState, value lua.LValue) []float64 {
+	numbers := []float64{}
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return numbers
+	}
+	table.ForEach(func(_, value lua.LValue) {
+		switch v := value.(type) {
+		case lua.LNumber:
+			numbers = append(numbers, float64(v))
+		case lua.LBool:
+			if v {
+				numbers = append(numbers, 1)
+			} else {
+				numbers = append(numbers, 0)
+			}
+		default:
+			ls.ArgError(2, "table of numbers expected")
+		}
+	})
+	return numbers
+}
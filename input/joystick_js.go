@@ -0,0 +1,11 @@
+package input
+
+// platformJoysticks has no joysticks in the browser yet.
+type platformJoysticks struct{}
+
+func (platformJoysticks) Present(id int) bool   { return false }
+func (platformJoysticks) Name(id int) string    { return "" }
+func (platformJoysticks) GUID(id int) string    { return "" }
+func (platformJoysticks) Axes(id int) []float32 { return nil }
+func (platformJoysticks) Buttons(id int) []bool { return nil }
+func (platformJoysticks) Hats(id int) []int     { return nil }
@@ -0,0 +1,29 @@
+// +build !js
+
+package input
+
+import (
+	glfw32 "github.com/go-gl/glfw/v3.2/glfw"
+)
+
+// platformJoysticks reads joystick data directly from go-gl glfw because goxjs
+// glfw does not wrap the joystick api. glfw 3.2 does not expose joystick GUIDs
+// or hats so mappings are matched by name and hat bindings like dpup:h0.1 never
+// fire. Depending on the platform glfw reports a hat as 4 extra buttons or as 2
+// axes so d-pads can only be used with mappings that bind those instead.
+type platformJoysticks struct{}
+
+func (platformJoysticks) Present(id int) bool   { return glfw32.JoystickPresent(glfw32.Joystick(id)) }
+func (platformJoysticks) Name(id int) string    { return glfw32.GetJoystickName(glfw32.Joystick(id)) }
+func (platformJoysticks) GUID(id int) string    { return "" }
+func (platformJoysticks) Axes(id int) []float32 { return glfw32.GetJoystickAxes(glfw32.Joystick(id)) }
+func (platformJoysticks) Hats(id int) []int     { return nil }
+
+func (platformJoysticks) Buttons(id int) []bool {
+	raw := glfw32.GetJoystickButtons(glfw32.Joystick(id))
+	buttons := make([]bool, len(raw))
+	for i, state := range raw {
+		buttons[i] = state == byte(glfw32.Press)
+	}
+	return buttons
+}
@@ -0,0 +1,36 @@
+package input
+
+import (
+	"unicode/utf8"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/goxjs/glfw"
+)
+
+// textListener is the keydown listener added to the page, kept so that it is
+// only added once across reloads.
+var textListener func(*js.Object)
+
+// listenForText will send the characters typed into the canvas to char. goxjs
+// glfw does not implement the char callback in the browser and prevents the
+// default of keydown events, so keypress never fires. Instead the key of each
+// keydown is used, which is the character produced by the keyboard layout. Key
+// events from the canvas bubble up to the document where glfw listens for them
+// too. Keys that do not produce a character are named, like Enter, and are
+// skipped like shortcuts with ctrl or meta. The char is called in a goroutine
+// like glfw calls the key callback so that it arrives after the key press.
+func listenForText(window *glfw.Window) {
+	if textListener != nil {
+		return
+	}
+	textListener = func(event *js.Object) {
+		if event.Get("ctrlKey").Bool() || event.Get("metaKey").Bool() {
+			return
+		}
+		key := event.Get("key").String()
+		if char, size := utf8.DecodeRuneInString(key); size > 0 && size == len(key) && char >= ' ' {
+			go currentCapture.char(window, char)
+		}
+	}
+	js.Global.Get("document").Call("addEventListener", "keydown", textListener, false)
+}
@@ -0,0 +1,12 @@
+// +build !js
+
+package input
+
+import (
+	"github.com/goxjs/glfw"
+)
+
+// listenForText will send the characters typed into the window to char
+func listenForText(window *glfw.Window) {
+	window.SetCharCallback(currentCapture.char)
+}
@@ -0,0 +1,249 @@
+package input
+
+import (
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/runtime"
+)
+
+var inputFunctions = runtime.LuaFuncs{
+	"isdown":         inputIsDown,
+	"pressed":        inputPressed,
+	"released":       inputReleased,
+	"repeated":       inputRepeated,
+	"gettext":        inputGetText,
+	"settextinput":   inputSetTextInput,
+	"hastextinput":   inputHasTextInput,
+	"setkeyrepeat":   inputSetKeyRepeat,
+	"haskeyrepeat":   inputHasKeyRepeat,
+	"getposition":    inputGetPosition,
+	"getscroll":      inputGetScroll,
+	"ismouseinside":  inputIsMouseInside,
+	"getgamepads":    inputGetGamepads,
+	"getgamepadname": inputGetGamepadName,
+	"isgamepaddown":  inputIsGamepadDown,
+	"getaxis":        inputGetAxis,
+	"setdeadzone":    inputSetDeadzone,
+	"loadmappings":   inputLoadMappings,
+	"addmapping":     inputAddMapping,
+	"bind":           inputBind,
+	"addbinding":     inputAddBinding,
+	"unbind":         inputUnbind,
+	"getbindings":    inputGetBindings,
+	"getactions":     inputGetActions,
+	"getactionvalue": inputGetActionValue,
+	"savebindings":   inputSaveBindings,
+	"loadbindings":   inputLoadBindings,
+}
+
+func inputIsDown(ls *lua.LState) int {
+	ls.Push(lua.LBool(currentCapture.isDown(ls.CheckString(1), ls.CheckString(2))))
+	return 1
+}
+
+func inputPressed(ls *lua.LState) int {
+	ls.Push(lua.LBool(currentCapture.pressed[ls.CheckString(1)][ls.CheckString(2)]))
+	return 1
+}
+
+func inputReleased(ls *lua.LState) int {
+	ls.Push(lua.LBool(currentCapture.released[ls.CheckString(1)][ls.CheckString(2)]))
+	return 1
+}
+
+func inputRepeated(ls *lua.LState) int {
+	ls.Push(lua.LBool(currentCapture.repeated[ls.CheckString(1)][ls.CheckString(2)]))
+	return 1
+}
+
+func inputGetText(ls *lua.LState) int {
+	ls.Push(lua.LString(currentCapture.text))
+	return 1
+}
+
+func inputSetTextInput(ls *lua.LState) int {
+	currentCapture.textInput = ls.ToBool(1)
+	return 0
+}
+
+func inputHasTextInput(ls *lua.LState) int {
+	ls.Push(lua.LBool(currentCapture.textInput))
+	return 1
+}
+
+func inputSetKeyRepeat(ls *lua.LState) int {
+	currentCapture.keyRepeat = ls.ToBool(1)
+	return 0
+}
+
+func inputHasKeyRepeat(ls *lua.LState) int {
+	ls.Push(lua.LBool(currentCapture.keyRepeat))
+	return 1
+}
+
+func inputGetPosition(ls *lua.LState) int {
+	ls.Push(lua.LNumber(currentCapture.mousex))
+	ls.Push(lua.LNumber(currentCapture.mousey))
+	return 2
+}
+
+func inputGetScroll(ls *lua.LState) int {
+	ls.Push(lua.LNumber(currentCapture.scrollx))
+	ls.Push(lua.LNumber(currentCapture.scrolly))
+	return 2
+}
+
+func inputIsMouseInside(ls *lua.LState) int {
+	ls.Push(lua.LBool(currentCapture.isInside))
+	return 1
+}
+
+func inputGetGamepads(ls *lua.LState) int {
+	ids := ls.NewTable()
+	for id := 0; id < maxJoysticks; id++ {
+		if _, ok := currentCapture.gamepads[id]; ok {
+			ids.Append(lua.LNumber(id))
+		}
+	}
+	ls.Push(ids)
+	return 1
+}
+
+func inputGetGamepadName(ls *lua.LState) int {
+	if pad, ok := currentCapture.gamepads[ls.CheckInt(1)]; ok {
+		ls.Push(lua.LString(pad.name))
+		return 1
+	}
+	ls.Push(lua.LNil)
+	return 1
+}
+
+func inputIsGamepadDown(ls *lua.LState) int {
+	pad, ok := currentCapture.gamepads[ls.CheckInt(1)]
+	ls.Push(lua.LBool(ok && pad.buttons[ls.CheckString(2)]))
+	return 1
+}
+
+func inputGetAxis(ls *lua.LState) int {
+	var value float32
+	if pad, ok := currentCapture.gamepads[ls.CheckInt(1)]; ok {
+		value = pad.axes[ls.CheckString(2)]
+	}
+	ls.Push(lua.LNumber(value))
+	return 1
+}
+
+func inputSetDeadzone(ls *lua.LState) int {
+	SetDeadzone(float32(ls.CheckNumber(1)), ls.OptString(2, ""))
+	return 0
+}
+
+func inputLoadMappings(ls *lua.LState) int {
+	if err := LoadMappings(ls.CheckString(1)); err != nil {
+		ls.Push(lua.LFalse)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(lua.LTrue)
+	return 1
+}
+
+func inputAddMapping(ls *lua.LState) int {
+	if err := AddMapping(ls.CheckString(1)); err != nil {
+		ls.Push(lua.LFalse)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(lua.LTrue)
+	return 1
+}
+
+// toBinding converts a table in the form of {device, button} or
+// {device, negative, positive} into a binding
+func toBinding(ls *lua.LState, offset int) Binding {
+	table := ls.CheckTable(offset)
+	binding := Binding{Device: table.RawGetInt(1).String()}
+	if table.Len() == 3 {
+		binding.Negative = table.RawGetInt(2).String()
+		binding.Positive = table.RawGetInt(3).String()
+	} else if table.Len() == 2 {
+		binding.Button = table.RawGetInt(2).String()
+	} else {
+		ls.ArgError(offset, "binding should be {device, button} or {device, negative, positive}")
+	}
+	return binding
+}
+
+func fromBinding(ls *lua.LState, binding Binding) *lua.LTable {
+	table := ls.NewTable()
+	table.Append(lua.LString(binding.Device))
+	if binding.Button != "" {
+		table.Append(lua.LString(binding.Button))
+	} else {
+		table.Append(lua.LString(binding.Negative))
+		table.Append(lua.LString(binding.Positive))
+	}
+	return table
+}
+
+func inputBind(ls *lua.LState) int {
+	bindings := []Binding{}
+	for offset := 2; offset <= ls.GetTop(); offset++ {
+		bindings = append(bindings, toBinding(ls, offset))
+	}
+	Bind(ls.CheckString(1), bindings...)
+	return 0
+}
+
+func inputAddBinding(ls *lua.LState) int {
+	AddBinding(ls.CheckString(1), toBinding(ls, 2))
+	return 0
+}
+
+func inputUnbind(ls *lua.LState) int {
+	Unbind(ls.CheckString(1))
+	return 0
+}
+
+func inputGetBindings(ls *lua.LState) int {
+	bindings := ls.NewTable()
+	for _, binding := range GetBindings(ls.CheckString(1)) {
+		bindings.Append(fromBinding(ls, binding))
+	}
+	ls.Push(bindings)
+	return 1
+}
+
+func inputGetActions(ls *lua.LState) int {
+	names := ls.NewTable()
+	for _, name := range GetActions() {
+		names.Append(lua.LString(name))
+	}
+	ls.Push(names)
+	return 1
+}
+
+func inputGetActionValue(ls *lua.LState) int {
+	ls.Push(lua.LNumber(GetActionValue(ls.CheckString(1))))
+	return 1
+}
+
+func inputSaveBindings(ls *lua.LState) int {
+	if err := SaveBindings(ls.CheckString(1)); err != nil {
+		ls.Push(lua.LFalse)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(lua.LTrue)
+	return 1
+}
+
+func inputLoadBindings(ls *lua.LState) int {
+	if err := LoadBindings(ls.CheckString(1)); err != nil {
+		ls.Push(lua.LFalse)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(lua.LTrue)
+	return 1
+}
@@ -0,0 +1,220 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/file"
+)
+
+// confFile is the optional lua file that is run before the window is created
+// to configure the window and the engine.
+const confFile = "conf.lua"
+
+type config struct {
+	Identity   string
+	Title      string
+	Width      int
+	Height     int
+	Resizable  bool
+	Fullscreen bool
+	MouseShown bool
+	Vsync      bool
+	Samples    int
+	Headless   bool    // run without a window
+	Timestep   float32 // fixed update timestep in seconds, 0 for a variable timestep
+	MaxSteps   int     // max fixed updates per frame before time is dropped
+	MaxDT      float32 // max dt that will be passed to update, 0 for no limit
+	FPS        int     // frame rate limit, 0 for no limit even when headless
+	Modules    map[string]bool
+}
+
+// Options are values given on the command line that take precedence over the
+// values set in conf.lua. Zero values are ignored.
+type Options struct {
+	Width        int
+	Height       int
+	Fullscreen   bool
+	Headless     bool
+	HeadlessDraw bool   // call draw when headless, graphics calls still do nothing
+	Watch        bool   // reload scripts and assets when they change
+	Console      bool   // enable the developer console
+	Events       string // path to a script of input events to feed the program
+	Frames       int    // quit after this many frames, 0 for no limit
+	Stats        bool   // enable the stats overlay
+	Profile      string // path to write a chrome trace of the frame phases to
+}
+
+var (
+	options Options
+	conf    = config{
+		Width:      800,
+		Height:     600,
+		MouseShown: true,
+		Vsync:      true,
+		Samples:    4,
+		MaxSteps:   5,
+		MaxDT:      0.25,
+	}
+)
+
+// loadConf will run conf.lua if it exists in a fresh lua state and will call
+// the conf(t) callback with a table filled with the default config. Whatever
+// values are set on the table are then read back into the config.
+func loadConf() (config, error) {
+	cfg := conf
+	if cfg.Identity == "" {
+		if wd, err := os.Getwd(); err == nil {
+			cfg.Identity = filepath.Base(wd)
+		}
+	}
+	cfg.Modules = map[string]bool{}
+	for _, mod := range registeredModules {
+		cfg.Modules[mod.name] = true
+	}
+
+	conffile, err := file.Open(confFile)
+	if err != nil {
+		return cfg, nil // no conf file, just use the defaults
+	}
+	defer conffile.Close()
+
+	ls := lua.NewState()
+	defer ls.Close()
+
+	if fn, err := ls.Load(conffile, confFile); err != nil {
+		return cfg, err
+	} else {
+		ls.Push(fn)
+		if err := ls.PCall(0, lua.MultRet, nil); err != nil {
+			return cfg, err
+		}
+	}
+
+	callback := ls.GetGlobal("conf")
+	if callback == lua.LNil {
+		return cfg, nil
+	}
+
+	table := cfg.toLua(ls)
+	if err := ls.CallByParam(lua.P{Fn: callback, Protect: true}, table); err != nil {
+		return cfg, err
+	}
+	cfg.fromLua(table)
+	if cfg.MaxSteps < 1 {
+		return cfg, fmt.Errorf("loop.maxsteps in %v must be at least 1, got %v", confFile, cfg.MaxSteps)
+	}
+	return cfg, nil
+}
+
+// SetOptions will set the options that override conf.lua the next time the
+// program is run.
+func SetOptions(opts Options) {
+	options = opts
+}
+
+// applyOptions will override the config with any options that have been set
+func (cfg *config) applyOptions(opts Options) {
+	if opts.Width > 0 {
+		cfg.Width = opts.Width
+	}
+	if opts.Height > 0 {
+		cfg.Height = opts.Height
+	}
+	if opts.Fullscreen {
+		cfg.Fullscreen = true
+	}
+	if opts.Headless || Replaying() {
+		cfg.Headless = true
+	}
+}
+
+// toLua will create a lua table representation of the config to be passed into
+// the conf callback.
+func (cfg config) toLua(ls *lua.LState) *lua.LTable {
+	window := ls.NewTable()
+	window.RawSetString("title", lua.LString(cfg.Title))
+	window.RawSetString("width", lua.LNumber(cfg.Width))
+	window.RawSetString("height", lua.LNumber(cfg.Height))
+	window.RawSetString("resizable", lua.LBool(cfg.Resizable))
+	window.RawSetString("fullscreen", lua.LBool(cfg.Fullscreen))
+	window.RawSetString("mouseshown", lua.LBool(cfg.MouseShown))
+	window.RawSetString("vsync", lua.LBool(cfg.Vsync))
+	window.RawSetString("msaa", lua.LNumber(cfg.Samples))
+
+	loop := ls.NewTable()
+	loop.RawSetString("timestep", lua.LNumber(cfg.Timestep))
+	loop.RawSetString("maxsteps", lua.LNumber(cfg.MaxSteps))
+	loop.RawSetString("maxdt", lua.LNumber(cfg.MaxDT))
+	loop.RawSetString("fps", lua.LNumber(cfg.FPS))
+
+	modules := ls.NewTable()
+	for name, enabled := range cfg.Modules {
+		modules.RawSetString(name, lua.LBool(enabled))
+	}
+
+	table := ls.NewTable()
+	table.RawSetString("identity", lua.LString(cfg.Identity))
+	table.RawSetString("window", window)
+	table.RawSetString("loop", loop)
+	table.RawSetString("modules", modules)
+	return table
+}
+
+// fromLua will read back the values of the table into the config, values that
+// have been removed or are the wrong type will keep thier default value.
+func (cfg *config) fromLua(table *lua.LTable) {
+	cfg.Identity = confString(table, "identity", cfg.Identity)
+	if window, ok := table.RawGetString("window").(*lua.LTable); ok {
+		cfg.Title = confString(window, "title", cfg.Title)
+		cfg.Width = confInt(window, "width", cfg.Width)
+		cfg.Height = confInt(window, "height", cfg.Height)
+		cfg.Resizable = confBool(window, "resizable", cfg.Resizable)
+		cfg.Fullscreen = confBool(window, "fullscreen", cfg.Fullscreen)
+		cfg.MouseShown = confBool(window, "mouseshown", cfg.MouseShown)
+		cfg.Vsync = confBool(window, "vsync", cfg.Vsync)
+		cfg.Samples = confInt(window, "msaa", cfg.Samples)
+	}
+	if loop, ok := table.RawGetString("loop").(*lua.LTable); ok {
+		cfg.Timestep = confFloat(loop, "timestep", cfg.Timestep)
+		cfg.MaxSteps = confInt(loop, "maxsteps", cfg.MaxSteps)
+		cfg.MaxDT = confFloat(loop, "maxdt", cfg.MaxDT)
+		cfg.FPS = confInt(loop, "fps", cfg.FPS)
+	}
+	if modules, ok := table.RawGetString("modules").(*lua.LTable); ok {
+		for name, enabled := range cfg.Modules {
+			cfg.Modules[name] = confBool(modules, name, enabled)
+		}
+	}
+}
+
+func confString(table *lua.LTable, key, fallback string) string {
+	if lv, ok := table.RawGetString(key).(lua.LString); ok {
+		return string(lv)
+	}
+	return fallback
+}
+
+func confInt(table *lua.LTable, key string, fallback int) int {
+	if lv, ok := table.RawGetString(key).(lua.LNumber); ok {
+		return int(lv)
+	}
+	return fallback
+}
+
+func confFloat(table *lua.LTable, key string, fallback float32) float32 {
+	if lv, ok := table.RawGetString(key).(lua.LNumber); ok {
+		return float32(lv)
+	}
+	return fallback
+}
+
+func confBool(table *lua.LTable, key string, fallback bool) bool {
+	if lv, ok := table.RawGetString(key).(lua.LBool); ok {
+		return bool(lv)
+	}
+	return fallback
+}
@@ -0,0 +1,342 @@
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/goxjs/glfw"
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/gfx"
+)
+
+const (
+	consoleToggle     = glfw.KeyGraveAccent
+	consoleMaxLines   = 200
+	consoleMaxHistory = 100
+	consolePadding    = 8
+)
+
+// console is a developer overlay that evaluates lua in the running state so that
+// the state of the game can be inspected and changed without restarting it.
+type console struct {
+	ls        *lua.LState
+	open      bool
+	input     []rune
+	lines     []string
+	history   []string
+	histIndex int
+	skipChar  bool
+	pressed   map[glfw.Key]bool // keys pressed into the console, their releases are not passed on
+}
+
+// devConsole is only created when the console is enabled with the --console option
+var devConsole *console
+
+// ConsoleKey will pass a key event to the developer console. It returns true if the
+// console used the event and it should not be passed on to the program. Releases
+// of keys pressed into the console are used as well, while releases of keys held
+// when the console opened are passed on so that they are not stuck down.
+func ConsoleKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) bool {
+	if devConsole == nil || devConsole.ls == nil {
+		return false
+	}
+	return devConsole.key(key, action, mods)
+}
+
+// ConsoleText will pass entered text to the developer console. It returns true if
+// the console used the text and it should not be passed on to the program.
+func ConsoleText(char rune) bool {
+	if devConsole == nil || devConsole.ls == nil {
+		return false
+	}
+	return devConsole.char(char)
+}
+
+// bind will attach the console to a new lua state and replace print so that its
+// output is shown in the console as well as on stdout.
+func (c *console) bind(ls *lua.LState) {
+	c.ls = ls
+	ls.SetGlobal("print", ls.NewFunction(c.print))
+}
+
+func (c *console) print(ls *lua.LState) int {
+	parts := []string{}
+	for i := 1; i <= ls.GetTop(); i++ {
+		parts = append(parts, ls.ToStringMeta(ls.Get(i)).String())
+	}
+	line := strings.Join(parts, "\t")
+	fmt.Println(line)
+	c.write(line)
+	return 0
+}
+
+// write will add output to the console, dropping the oldest lines once there
+// are more than consoleMaxLines.
+func (c *console) write(text string) {
+	c.lines = append(c.lines, strings.Split(text, "\n")...)
+	if len(c.lines) > consoleMaxLines {
+		c.lines = c.lines[len(c.lines)-consoleMaxLines:]
+	}
+}
+
+func (c *console) key(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) bool {
+	if action == glfw.Release {
+		pressed := c.pressed[key]
+		delete(c.pressed, key)
+		return pressed
+	}
+	used := c.press(key, action)
+	if used && action == glfw.Press {
+		if c.pressed == nil {
+			c.pressed = map[glfw.Key]bool{}
+		}
+		c.pressed[key] = true
+	}
+	return used
+}
+
+// press will handle a key press or repeat and return true if the console used it
+func (c *console) press(key glfw.Key, action glfw.Action) bool {
+	if key == consoleToggle {
+		if action == glfw.Press {
+			c.open = !c.open
+			c.skipChar = true
+		}
+		return true
+	}
+	if !c.open {
+		return false
+	}
+
+	switch key {
+	case glfw.KeyEnter, glfw.KeyKPEnter:
+		line := string(c.input)
+		c.input = nil
+		if strings.TrimSpace(line) != "" {
+			c.eval(line)
+		}
+	case glfw.KeyBackspace:
+		if len(c.input) > 0 {
+			c.input = c.input[:len(c.input)-1]
+		}
+	case glfw.KeyUp:
+		c.browseHistory(-1)
+	case glfw.KeyDown:
+		c.browseHistory(1)
+	case glfw.KeyTab:
+		c.complete()
+	case glfw.KeyEscape:
+		c.open = false
+	}
+	return true
+}
+
+// char will add typed text to the input line. The character typed by the toggle
+// key is skipped so that it does not end up in the input.
+func (c *console) char(char rune) bool {
+	skip := c.skipChar
+	c.skipChar = false
+	if skip && (char == '`' || char == '~') {
+		return true
+	}
+	if !c.open {
+		return false
+	}
+	c.input = append(c.input, char)
+	return true
+}
+
+// eval will run the line in the lua state and write out any results. The line is
+// first tried as an expression so that values can be inspected by just typing
+// them, then as a statement.
+func (c *console) eval(line string) {
+	c.write("> " + line)
+	if len(c.history) == 0 || c.history[len(c.history)-1] != line {
+		c.history = append(c.history, line)
+		if len(c.history) > consoleMaxHistory {
+			c.history = c.history[1:]
+		}
+	}
+	c.histIndex = len(c.history)
+
+	fn, err := c.ls.LoadString("return " + line)
+	if err != nil {
+		if fn, err = c.ls.LoadString(line); err != nil {
+			c.write(err.Error())
+			return
+		}
+	}
+
+	top := c.ls.GetTop()
+	defer c.ls.SetTop(top)
+	c.ls.Push(fn)
+	if err := c.ls.PCall(0, lua.MultRet, nil); err != nil {
+		msg, _ := splitError(err)
+		c.write(msg)
+		return
+	}
+	results := []string{}
+	for i := top + 1; i <= c.ls.GetTop(); i++ {
+		results = append(results, c.ls.ToStringMeta(c.ls.Get(i)).String())
+	}
+	if len(results) > 0 {
+		c.write(strings.Join(results, "\t"))
+	}
+}
+
+// browseHistory will move through the previously evaluated lines. Moving past
+// the newest line clears the input.
+func (c *console) browseHistory(direction int) {
+	index := c.histIndex + direction
+	if index < 0 || len(c.history) == 0 {
+		return
+	}
+	if index >= len(c.history) {
+		c.histIndex = len(c.history)
+		c.input = nil
+		return
+	}
+	c.histIndex = index
+	c.input = []rune(c.history[index])
+}
+
+// complete will complete the name at the end of the input line. Names are completed
+// from the globals, or from the fields of a table or module when the name follows
+// a dot or colon. If there are many matches the input is completed as far as they
+// agree and the matches are written out.
+func (c *console) complete() {
+	line := string(c.input)
+	start := len(line)
+	for start > 0 && isNameChar(line[start-1]) {
+		start--
+	}
+	word := line[start:]
+	path, partial := "", word
+	if split := strings.LastIndexAny(word, ".:"); split >= 0 {
+		path, partial = word[:split], word[split+1:]
+	}
+
+	matches := c.completions(path, partial)
+	if len(matches) == 0 {
+		return
+	}
+	completion := matches[0]
+	for _, match := range matches[1:] {
+		for !strings.HasPrefix(match, completion) {
+			completion = completion[:len(completion)-1]
+		}
+	}
+	if len(matches) > 1 {
+		c.write(strings.Join(matches, "  "))
+	}
+	c.input = []rune(line[:len(line)-len(partial)] + completion)
+}
+
+func (c *console) completions(path, partial string) []string {
+	names := map[string]bool{}
+	addKeys := func(table *lua.LTable) {
+		table.ForEach(func(key, value lua.LValue) {
+			if name, ok := key.(lua.LString); ok {
+				names[string(name)] = true
+			}
+		})
+	}
+
+	if path == "" {
+		addKeys(c.ls.G.Global)
+	} else {
+		for _, mod := range registeredModules {
+			if mod.name != path {
+				continue
+			}
+			for name := range mod.functions {
+				names[name] = true
+			}
+		}
+		if table, ok := c.lookup(path).(*lua.LTable); ok {
+			addKeys(table)
+		}
+	}
+
+	matches := []string{}
+	for name := range names {
+		if strings.HasPrefix(name, partial) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// lookup will find the table at a path of names like gfx or player.pos. For
+// userdata the methods in its metatable are returned.
+func (c *console) lookup(path string) lua.LValue {
+	var value lua.LValue = c.ls.G.Global
+	for _, name := range strings.FieldsFunc(path, func(r rune) bool { return r == '.' || r == ':' }) {
+		table, ok := value.(*lua.LTable)
+		if !ok {
+			return lua.LNil
+		}
+		value = c.ls.GetField(table, name)
+	}
+	if ud, ok := value.(*lua.LUserData); ok {
+		return c.ls.GetMetaField(ud, "__index")
+	}
+	return value
+}
+
+func isNameChar(char byte) bool {
+	return char == '_' || char == '.' || char == ':' ||
+		(char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+}
+
+// draw will draw the console over the top half of the screen. Any state that the
+// program left set is restored afterwards.
+func (c *console) draw() {
+	if !c.open {
+		return
+	}
+
+	drawOverlay(func() {
+		width, height := gfx.GetWidth(), gfx.GetHeight()/2
+		lineHeight := gfx.GetFont().GetHeight()
+		gfx.SetColor(0, 0, 0, 0.8)
+		gfx.Rect("fill", 0, 0, width, height)
+		gfx.SetColor(1, 1, 1, 1)
+		gfx.Rect("fill", 0, height, width, 1)
+
+		white := [][]float32{{1, 1, 1, 1}}
+		y := height - consolePadding - lineHeight
+		gfx.Print([]string{"> " + string(c.input) + "_"}, white, consolePadding, y)
+		for i := len(c.lines) - 1; i >= 0 && y > consolePadding+lineHeight; i-- {
+			y -= lineHeight
+			gfx.Print([]string{c.lines[i]}, white, consolePadding, y)
+		}
+	})
+}
+
+// drawOverlay will call draw on the screen with no transform, shader or stencil
+// test and the default font set. Any state that the program left set is restored
+// afterwards and nothing that is drawn is counted in the graphics stats.
+func drawOverlay(draw func()) {
+	color := gfx.GetColor()
+	shader := gfx.GetShader()
+	canvas := gfx.GetCanvas()
+	compare, value := gfx.GetStencilTest()
+	gfx.IgnoreStats(func() {
+		gfx.Push()
+		gfx.Origin()
+		gfx.SetCanvas(nil)
+		gfx.SetShader(nil)
+		gfx.ClearStencilTest()
+		gfx.SetFont(nil)
+		draw()
+		gfx.Pop()
+		gfx.SetColor(color[0], color[1], color[2], color[3])
+		gfx.SetShader(shader)
+		gfx.SetCanvas(canvas)
+		gfx.SetStencilTest(compare, value)
+	})
+}
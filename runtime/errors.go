@@ -0,0 +1,119 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goxjs/glfw"
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/gfx"
+)
+
+const errorHelp = "Press ctrl+c to copy this error, escape to quit"
+
+// reportedError is the first error reported by a callback outside of update
+// and draw during the current frame.
+var reportedError error
+
+// errorScreen is drawn in place of the game after an error so that the message
+// and traceback can be read, copied, and fixed with a reload.
+type errorScreen struct {
+	win     *window
+	message string
+}
+
+// ReportError will report an error from a lua callback that was called outside of
+// update and draw, like input callbacks, so that it is handled the same way as an
+// error in update. Only the first error in a frame is kept.
+func ReportError(err error) {
+	if reportedError == nil {
+		reportedError = err
+	}
+}
+
+// takeReportedError will return the reported error and clear it for the next frame
+func takeReportedError() error {
+	err := reportedError
+	reportedError = nil
+	return err
+}
+
+// handleError will log the error and pass it to the onerror callback of the program
+// if it has one. If onerror returns true the error is considered handled and the
+// game keeps running. Otherwise an error screen is returned to be shown in place of
+// the game. When running headless or replaying there is nobody to read the
+// screen so the error is returned to stop the program.
+func handleError(ls *lua.LState, win *window, err error) (*errorScreen, error) {
+	msg, trace := splitError(err)
+	Errorf("%v\n%v", msg, trace)
+	if Replaying() || conf.Headless {
+		return nil, err
+	}
+
+	if ls != nil {
+		if callback := ls.GetGlobal("onerror"); callback != lua.LNil {
+			callErr := ls.CallByParam(lua.P{Fn: callback, NRet: 1, Protect: true}, lua.LString(msg), lua.LString(trace))
+			if callErr == nil {
+				handled := ls.Get(-1)
+				ls.Pop(1)
+				if lua.LVAsBool(handled) {
+					return nil, nil
+				}
+			} else {
+				errMsg, errTrace := splitError(callErr)
+				Errorf("error in onerror: %v\n%v", errMsg, errTrace)
+				msg = fmt.Sprintf("%v\n\nerror in onerror: %v", msg, errMsg)
+			}
+		}
+	}
+
+	return newErrorScreen(win, msg, trace), nil
+}
+
+// splitError will separate the message of an error from its lua traceback
+func splitError(err error) (string, string) {
+	if apiErr, ok := err.(*lua.ApiError); ok {
+		return apiErr.Object.String(), strings.TrimSpace(apiErr.StackTrace)
+	}
+	return err.Error(), ""
+}
+
+func newErrorScreen(win *window, msg, trace string) *errorScreen {
+	message := msg
+	if trace != "" {
+		message += "\n\n" + trace
+	}
+	screen := &errorScreen{win: win, message: message}
+	win.SetKeyCallback(screen.key)
+	return screen
+}
+
+func (screen *errorScreen) key(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press {
+		return
+	}
+	switch {
+	case key == glfw.KeyEscape:
+		w.SetShouldClose(true)
+	case key == glfw.KeyC && (mods&glfw.ModControl != 0 || mods&glfw.ModSuper != 0):
+		w.SetClipboardString(screen.message)
+	}
+}
+
+// draw will reset any state left behind by the program and draw the error
+func (screen *errorScreen) draw() {
+	if !screen.win.active {
+		return
+	}
+	gfx.SetCanvas(nil)
+	gfx.SetShader(nil)
+	gfx.ClearScissor()
+	gfx.ClearStencilTest()
+	gfx.Origin()
+	gfx.Clear(0.35, 0.62, 0.86, 1)
+	gfx.SetColor(1, 1, 1, 1)
+	gfx.Printf([]string{screen.message + "\n\n" + errorHelp}, [][]float32{{1, 1, 1, 1}}, gfx.GetWidth()-140, "left", 70, 70)
+	gfx.Present()
+	screen.win.SwapBuffers()
+}
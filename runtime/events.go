@@ -0,0 +1,132 @@
+package runtime
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	frameCount     int             // frames stepped since the program started
+	pushedEvents   []Event         // events to feed at the start of the next frame
+	scriptedEvents map[int][]Event // events from an event script by frame
+)
+
+// PushEvent will feed the event to the program at the start of the next frame as
+// if it had come from the device. This is how input is given to a program that
+// is running headless.
+func PushEvent(event Event) error {
+	if err := checkEvent(event); err != nil {
+		return err
+	}
+	pushedEvents = append(pushedEvents, event)
+	return nil
+}
+
+// resetEvents will clear any events and the frame count for a new run
+func resetEvents() {
+	frameCount = 0
+	pushedEvents = nil
+	scriptedEvents = map[int][]Event{}
+}
+
+// loadEventScript will read a script of events to feed the program. Each line is
+// an event with the frame it should happen in, the device, button and action,
+// followed by any modifiers prefixed with + and any values. Buttons with spaces,
+// like entered text, can be quoted and lines starting with # are ignored.
+//
+//	# frame device button action [+modifier...] [value...]
+//	10 keyboard space press
+//	12 keyboard a press +shift
+//	30 mouse move move 100 200
+//	40 text "hello world" input
+func loadEventScript(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		frame, event, err := parseEvent(text)
+		if err != nil {
+			return fmt.Errorf("%v:%v: %v", path, line, err)
+		}
+		scriptedEvents[frame] = append(scriptedEvents[frame], event)
+	}
+	return scanner.Err()
+}
+
+func parseEvent(text string) (int, Event, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = ' '
+	reader.TrimLeadingSpace = true
+	fields, err := reader.Read()
+	if err != nil {
+		return 0, Event{}, err
+	}
+	if len(fields) < 4 {
+		return 0, Event{}, fmt.Errorf("expected a frame, device, button and action")
+	}
+	frame, err := strconv.Atoi(fields[0])
+	if err != nil || frame < 1 {
+		return 0, Event{}, fmt.Errorf("invalid frame %q", fields[0])
+	}
+
+	event := Event{Device: fields[1], Button: fields[2], Action: fields[3], Modifiers: []string{}}
+	for _, field := range fields[4:] {
+		if field == "" {
+			continue
+		} else if strings.HasPrefix(field, "+") {
+			event.Modifiers = append(event.Modifiers, field[1:])
+			continue
+		}
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return 0, Event{}, fmt.Errorf("invalid value %q", field)
+		}
+		event.Values = append(event.Values, value)
+	}
+	return frame, event, checkEvent(event)
+}
+
+// checkEvent will make sure the event has all the values its action needs
+func checkEvent(event Event) error {
+	needed := 0
+	switch {
+	case event.Device == "mouse" && (event.Action == "move" || event.Action == "scroll"):
+		needed = 2
+	case event.Device == "gamepad" && event.Action == "axis":
+		needed = 2
+	case event.Device == "gamepad":
+		needed = 1
+	}
+	if len(event.Values) < needed {
+		return fmt.Errorf("%v %v needs %v values", event.Device, event.Action, needed)
+	}
+	return nil
+}
+
+// feedEvents will pass any pushed events and the scripted events for the current
+// frame to the event hooks.
+func feedEvents() {
+	events := append(pushedEvents, scriptedEvents[frameCount]...)
+	pushedEvents = nil
+	for _, event := range events {
+		dispatchEvent(event)
+	}
+}
+
+func dispatchEvent(event Event) {
+	for _, fn := range registeredEventHooks {
+		fn(event)
+	}
+}
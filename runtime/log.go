@@ -0,0 +1,83 @@
+package runtime
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// LogLevel is the minimum level of messages that will be logged
+type LogLevel int
+
+// Log levels from the most verbose to the least
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+	LogNone
+)
+
+var (
+	logLevel  = LogWarn
+	logger    = log.New(os.Stderr, "", log.Ltime)
+	logLevels = map[string]LogLevel{
+		"debug": LogDebug,
+		"info":  LogInfo,
+		"warn":  LogWarn,
+		"error": LogError,
+		"none":  LogNone,
+	}
+
+	logFunctions = LuaFuncs{
+		"debug": logFunc(LogDebug),
+		"info":  logFunc(LogInfo),
+		"warn":  logFunc(LogWarn),
+		"error": logFunc(LogError),
+	}
+)
+
+// SetLogLevel will set the minimum level of messages that will be logged by
+// name, one of debug, info, warn, error or none.
+func SetLogLevel(name string) error {
+	level, ok := logLevels[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown log level %v", name)
+	}
+	logLevel = level
+	return nil
+}
+
+// Debugf will log a message that is only useful while debugging the engine
+func Debugf(format string, args ...interface{}) { logf(LogDebug, format, args...) }
+
+// Infof will log a message about the normal operation of the program
+func Infof(format string, args ...interface{}) { logf(LogInfo, format, args...) }
+
+// Warnf will log a message about something that went wrong but was recovered from
+func Warnf(format string, args ...interface{}) { logf(LogWarn, format, args...) }
+
+// Errorf will log a message about something that went wrong
+func Errorf(format string, args ...interface{}) { logf(LogError, format, args...) }
+
+func logf(level LogLevel, format string, args ...interface{}) {
+	if level < logLevel {
+		return
+	}
+	prefix := []string{"[debug] ", "[info] ", "[warn] ", "[error] "}[level]
+	logger.Printf(prefix+format, args...)
+}
+
+func logFunc(level LogLevel) lua.LGFunction {
+	return func(ls *lua.LState) int {
+		parts := []string{}
+		for i := 1; i <= ls.GetTop(); i++ {
+			parts = append(parts, ls.ToStringMeta(ls.Get(i)).String())
+		}
+		logf(level, "%v", strings.Join(parts, " "))
+		return 0
+	}
+}
@@ -0,0 +1,126 @@
+package runtime
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/tanema/amore/gfx"
+)
+
+// tracer writes the phases of every frame to a file as chrome trace events so
+// that a slow frame can be looked at in chrome://tracing or ui.perfetto.dev.
+type tracer struct {
+	file    *os.File
+	out     *bufio.Writer
+	start   time.Time
+	written bool
+	err     error
+}
+
+type traceEvent struct {
+	Name  string                 `json:"name"`
+	Phase string                 `json:"ph"`
+	Time  float64                `json:"ts"`
+	Dur   float64                `json:"dur,omitempty"`
+	PID   int                    `json:"pid"`
+	TID   int                    `json:"tid"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// profiler is only created when profiling with the --profile option
+var profiler *tracer
+
+// newTracer will create the trace file at path. Frame phases are traced on
+// thread 1 and garbage collection pauses on thread 2.
+func newTracer(path string) (*tracer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &tracer{file: f, out: bufio.NewWriter(f), start: time.Now()}
+	t.out.WriteString("[")
+	t.metadata(1, "frames")
+	t.metadata(2, "gc")
+	return t, nil
+}
+
+func (t *tracer) metadata(tid int, name string) {
+	t.write(traceEvent{Name: "thread_name", Phase: "M", PID: 1, TID: tid, Args: map[string]interface{}{"name": name}})
+}
+
+// span will add a phase that ran from start to end
+func (t *tracer) span(name string, tid int, start, end time.Time) {
+	t.write(traceEvent{
+		Name:  name,
+		Phase: "X",
+		Time:  t.micros(start),
+		Dur:   float64(end.Sub(start).Nanoseconds()) / 1000,
+		PID:   1,
+		TID:   tid,
+	})
+}
+
+// collections will add the garbage collection pauses that finished since there
+// were the previous number of collections.
+func (t *tracer) collections(stats *debug.GCStats, previous int64) {
+	count := int(stats.NumGC - previous)
+	if count > len(stats.PauseEnd) {
+		count = len(stats.PauseEnd) // older pauses are no longer kept
+	}
+	// the pauses are listed from the most recent so the oldest is added first
+	for i := count - 1; i >= 0; i-- {
+		end := stats.PauseEnd[i]
+		t.span("gc", 2, end.Add(-stats.Pause[i]), end)
+	}
+}
+
+// frameStats will add counters for the stats of the frame that just finished
+func (t *tracer) frameStats(stats gfx.Stats) {
+	now := t.micros(time.Now())
+	t.write(traceEvent{Name: "draw calls", Phase: "C", Time: now, PID: 1, TID: 1, Args: map[string]interface{}{
+		"draw calls":      stats.DrawCalls,
+		"texture binds":   stats.TextureBinds,
+		"shader switches": stats.ShaderSwitches,
+		"canvas switches": stats.CanvasSwitches,
+	}})
+	t.write(traceEvent{Name: "vertices", Phase: "C", Time: now, PID: 1, TID: 1, Args: map[string]interface{}{
+		"vertices": stats.Vertices,
+	}})
+}
+
+func (t *tracer) micros(at time.Time) float64 {
+	return float64(at.Sub(t.start).Nanoseconds()) / 1000
+}
+
+// write will add the event to the array of events. Only the first error is kept
+// and returned when the trace is closed.
+func (t *tracer) write(event traceEvent) {
+	if t.err != nil {
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.err = err
+		return
+	}
+	if t.written {
+		t.out.WriteString(",\n")
+	}
+	t.written = true
+	_, t.err = t.out.Write(data)
+}
+
+// close will end the array of events and close the file
+func (t *tracer) close() error {
+	t.out.WriteString("]\n")
+	if err := t.out.Flush(); t.err == nil {
+		t.err = err
+	}
+	if err := t.file.Close(); t.err == nil {
+		t.err = err
+	}
+	return t.err
+}
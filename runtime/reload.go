@@ -0,0 +1,199 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/file"
+	"github.com/tanema/amore/gfx"
+)
+
+// watchInterval is how often the game directory is checked for changes
+const watchInterval = 500 * time.Millisecond
+
+// watcher polls the modification times of all the files in a directory so that
+// changed scripts and assets can be reloaded.
+type watcher struct {
+	root      string
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+func newWatcher(root string) *watcher {
+	w := &watcher{root: root, lastCheck: time.Now()}
+	w.modTimes = w.scan()
+	return w
+}
+
+// changes will return the paths of all the files that have been added or
+// modified since the last check. The directory is only checked once every
+// watchInterval.
+func (w *watcher) changes() []string {
+	if time.Since(w.lastCheck) < watchInterval {
+		return nil
+	}
+	w.lastCheck = time.Now()
+
+	changed := []string{}
+	modTimes := w.scan()
+	for path, modTime := range modTimes {
+		if prev, ok := w.modTimes[path]; !ok || !prev.Equal(modTime) {
+			changed = append(changed, path)
+		}
+	}
+	w.modTimes = modTimes
+	return changed
+}
+
+func (w *watcher) scan() map[string]time.Time {
+	modTimes := map[string]time.Time{}
+	filepath.Walk(w.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != w.root && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			if rel, err := filepath.Rel(w.root, path); err == nil {
+				modTimes[filepath.ToSlash(rel)] = info.ModTime()
+			}
+		}
+		return nil
+	})
+	return modTimes
+}
+
+// reload will reload any changed assets in place. If any scripts have changed
+// a new lua state is loaded to replace the current one. Scripts are checked for
+// syntax errors first so that a typo does not throw away the running game. If
+// the new state fails to load it is closed and the current state is restored and
+// returned with the error.
+func reload(ls *lua.LState, win *window, entrypoint string, changed []string) (*lua.LState, error) {
+	scripts := false
+	for _, path := range changed {
+		if filepath.Ext(path) == ".lua" {
+			scripts = true
+			continue
+		}
+		if count, err := gfx.ReloadFile(path); err != nil {
+			Warnf("could not reload %v: %v", path, err)
+		} else if count > 0 {
+			Infof("reloaded %v", path)
+		}
+	}
+	if !scripts {
+		return ls, nil
+	}
+
+	for _, path := range changed {
+		if filepath.Ext(path) != ".lua" {
+			continue
+		}
+		if err := checkSyntax(path); err != nil {
+			Warnf("not reloading: %v", err)
+			return ls, nil
+		}
+	}
+
+	Infof("reloading scripts")
+	restore := saveState(ls, win)
+	gfx.ResetHotReload()
+	newState, err := loadState(entrypoint, win, ls)
+	if err != nil {
+		newState.Close()
+		restore()
+		return ls, err
+	}
+	if ls != nil {
+		ls.Close()
+	}
+	return newState, nil
+}
+
+// saveState will save everything that loading a new lua state replaces, the
+// window and console bindings, the state of the load hooks and the resources
+// tracked for hot reloading. The returned function puts it all back.
+func saveState(ls *lua.LState, win *window) func() {
+	restores := []func(){gfx.SaveHotReload()}
+	for _, fn := range registeredSaveHooks {
+		restores = append(restores, fn())
+	}
+	return func() {
+		for _, restore := range restores {
+			restore()
+		}
+		win.bind(ls)
+		if devConsole != nil {
+			devConsole.bind(ls)
+		}
+	}
+}
+
+// checkSyntax will compile the script without running it
+func checkSyntax(path string) error {
+	script, err := file.Open(path)
+	if err != nil {
+		return err
+	}
+	defer script.Close()
+	ls := lua.NewState()
+	defer ls.Close()
+	_, err = ls.Load(script, path)
+	return err
+}
+
+// globalNames will return the names of all the globals currently defined
+func globalNames(ls *lua.LState) map[string]bool {
+	names := map[string]bool{}
+	ls.G.Global.ForEach(func(key, value lua.LValue) {
+		names[key.String()] = true
+	})
+	return names
+}
+
+// copyGlobals will copy all the globals that were defined by the program in the
+// previous state into a table in the new state. Only plain values and tables are
+// copied, functions and userdata belong to the old state and are skipped.
+func copyGlobals(from, to *lua.LState, builtins map[string]bool) *lua.LTable {
+	state := to.NewTable()
+	seen := map[*lua.LTable]*lua.LTable{}
+	from.G.Global.ForEach(func(key, value lua.LValue) {
+		if builtins[key.String()] {
+			return
+		}
+		if copied := copyValue(to, value, seen); copied != lua.LNil {
+			state.RawSet(key, copied)
+		}
+	})
+	return state
+}
+
+func copyValue(to *lua.LState, value lua.LValue, seen map[*lua.LTable]*lua.LTable) lua.LValue {
+	switch val := value.(type) {
+	case lua.LBool, lua.LNumber, lua.LString:
+		return val
+	case *lua.LTable:
+		if copied, ok := seen[val]; ok {
+			return copied
+		}
+		copied := to.NewTable()
+		seen[val] = copied
+		val.ForEach(func(key, value lua.LValue) {
+			k := copyValue(to, key, seen)
+			v := copyValue(to, value, seen)
+			if k != lua.LNil && v != lua.LNil {
+				copied.RawSet(k, v)
+			}
+		})
+		return copied
+	}
+	return lua.LNil
+}
@@ -0,0 +1,162 @@
+package runtime
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Event is a single input event that was dispatched to the lua state. Events
+// are recorded along with the timestep of the frame they were received in so
+// that a run can be replayed exactly.
+type Event struct {
+	Device    string
+	Button    string
+	Action    string
+	Modifiers []string
+	Values    []float64
+}
+
+// EventHook is a function that will be called with every replayed event so that
+// it can update any state and dispatch it to the lua state like it was live.
+type EventHook func(Event)
+
+// recordingHeader is the first value in a recording and holds everything needed
+// to start the program in the same state.
+type recordingHeader struct {
+	Entrypoint string
+	Seed       int64
+}
+
+// recordedFrame is a single iteration of the gameloop
+type recordedFrame struct {
+	DT     float32
+	Events []Event
+}
+
+type recorder struct {
+	encoder *gob.Encoder
+	pending []Event
+}
+
+type player struct {
+	decoder *gob.Decoder
+	header  recordingHeader
+}
+
+var (
+	registeredEventHooks = []EventHook{}
+	currentRecorder      *recorder
+	currentPlayer        *player
+)
+
+// RegisterEventHook will add a hook that is called with every replayed event
+func RegisterEventHook(fn EventHook) {
+	registeredEventHooks = append(registeredEventHooks, fn)
+}
+
+// RecordEvent will add the event to the frame currently being recorded. If the
+// program is not being recorded this does nothing.
+func RecordEvent(event Event) {
+	if currentRecorder != nil {
+		currentRecorder.pending = append(currentRecorder.pending, event)
+	}
+}
+
+// Replaying will return true if the events are being fed from a recording. Live
+// input should be ignored while replaying.
+func Replaying() bool {
+	return currentPlayer != nil
+}
+
+// Record will run the program at the entrypoint like Run and record all of the
+// input events and frame timesteps into the file at path.
+func Record(entrypoint, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zip := gzip.NewWriter(f)
+	currentRecorder = &recorder{encoder: gob.NewEncoder(zip)}
+	defer func() { currentRecorder = nil }()
+
+	header := recordingHeader{Entrypoint: entrypoint, Seed: time.Now().UnixNano()}
+	if err := currentRecorder.encoder.Encode(header); err != nil {
+		return err
+	}
+	rand.Seed(header.Seed)
+
+	runErr := Run(entrypoint)
+	if err := zip.Close(); err != nil && runErr == nil {
+		return err
+	}
+	return runErr
+}
+
+// Replay will run the program recorded in the file at path, feeding it the same
+// events and timesteps that were recorded. The program is run headless while
+// replaying so live input is ignored.
+func Replay(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zip, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("could not read recording %v: %v", path, err)
+	}
+	currentPlayer = &player{decoder: gob.NewDecoder(zip)}
+	defer func() { currentPlayer = nil }()
+
+	if err := currentPlayer.decoder.Decode(&currentPlayer.header); err != nil {
+		return fmt.Errorf("could not read recording %v: %v", path, err)
+	}
+	rand.Seed(currentPlayer.header.Seed)
+	return Run(currentPlayer.header.Entrypoint)
+}
+
+// frameStep will return the timestep for this iteration of the gameloop. When
+// recording the step and all the events received since the last step are saved.
+// When replaying the step is read from the recording and its events are fed
+// to the event hooks, otherwise any pushed or scripted events are fed. io.EOF is
+// returned once the recording has ended or the frame limit has been reached.
+func frameStep() (float32, error) {
+	frameCount++
+	if options.Frames > 0 && frameCount > options.Frames {
+		return 0, io.EOF
+	}
+
+	if currentPlayer != nil {
+		var frame recordedFrame
+		if err := currentPlayer.decoder.Decode(&frame); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		for _, event := range frame.Events {
+			dispatchEvent(event)
+		}
+		return frame.DT, nil
+	}
+
+	feedEvents()
+	dt := step()
+	if currentRecorder != nil {
+		frame := recordedFrame{DT: dt, Events: currentRecorder.pending}
+		currentRecorder.pending = nil
+		if err := currentRecorder.encoder.Encode(frame); err != nil {
+			return dt, err
+		}
+	}
+	return dt, nil
+}
@@ -0,0 +1,51 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/file"
+)
+
+// requirePaths are the paths tried when requiring a module, the ? is replaced
+// with the module name with the dots replaced by slashes.
+var requirePaths = []string{"?.lua", "?/init.lua"}
+
+// installRequire will replace the default lua loader, which searches the OS
+// filesystem using package.path, with one that opens modules with the file
+// package so that bundled modules can be required.
+func installRequire(ls *lua.LState) {
+	loaders, ok := ls.GetField(ls.GetGlobal("package"), "loaders").(*lua.LTable)
+	if !ok {
+		return
+	}
+	loaders.RawSetInt(2, ls.NewFunction(requireLoader))
+}
+
+// requireLoader will find the module in the bundle or on disk and return the
+// loaded chunk. If it cannot be found a message listing every path that was
+// tried is returned for require to report.
+func requireLoader(ls *lua.LState) int {
+	name := ls.CheckString(1)
+	modpath := strings.Replace(name, ".", "/", -1)
+	tried := []string{}
+	for _, template := range requirePaths {
+		path := strings.Replace(template, "?", modpath, -1)
+		src, err := file.Open(path)
+		if err != nil {
+			tried = append(tried, fmt.Sprintf("no file '%v'", path))
+			continue
+		}
+		fn, err := ls.Load(src, path)
+		src.Close()
+		if err != nil {
+			ls.RaiseError("error loading module %v from %v: %v", name, path, err)
+		}
+		ls.Push(fn)
+		return 1
+	}
+	ls.Push(lua.LString(strings.Join(tried, "\n\t")))
+	return 1
+}
@@ -1,9 +1,10 @@
 package runtime
 
 import (
+	"io"
 	"runtime"
+	"time"
 
-	"github.com/goxjs/gl"
 	"github.com/goxjs/glfw"
 	"github.com/yuin/gopher-lua"
 
@@ -18,9 +19,24 @@ type LuaFuncs map[string]lua.LGFunction
 type LuaMetaTable map[string]LuaFuncs
 
 // LuaLoadHook is a function that will be called before the gameloop starts with
-// the state. This is good for fetching global callbacks to call later.
+// the state. This is good for fetching global callbacks to call later. The window
+// is nil when running headless.
 type LuaLoadHook func(*lua.LState, *glfw.Window)
 
+// FrameHook is a function that will be called at the end of every iteration of
+// the gameloop, right before events are polled for the next frame. This is good
+// for resetting any per frame state.
+type FrameHook func()
+
+// UpdateHook is a function that will be called with the timestep before every
+// call to update. This is good for anything that needs to advance with game time.
+type UpdateHook func(dt float32) error
+
+// SaveHook is a function that will be called before a new lua state is loaded to
+// replace the current one when reloading. It returns a function that will put
+// back the saved state if the new lua state fails to load.
+type SaveHook func() (restore func())
+
 type luaModule struct {
 	name       string
 	functions  LuaFuncs
@@ -28,13 +44,18 @@ type luaModule struct {
 }
 
 var (
-	registeredModules = []luaModule{}
-	registeredHooks   = []LuaLoadHook{}
+	registeredModules     = []luaModule{}
+	registeredHooks       = []LuaLoadHook{}
+	registeredFrameHooks  = []FrameHook{}
+	registeredUpdateHooks = []UpdateHook{}
+	registeredSaveHooks   = []SaveHook{}
 )
 
 func init() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	runtime.LockOSThread() //important OpenGl Demand it and stamp thier feet if you dont
+	RegisterModule("window", windowFunctions, nil)
+	RegisterModule("log", logFunctions, nil)
 }
 
 // RegisterModule registers a lua module within the global namespace for easy access
@@ -42,54 +63,147 @@ func RegisterModule(name string, funcs LuaFuncs, metatables LuaMetaTable) {
 	registeredModules = append(registeredModules, luaModule{name: name, functions: funcs, metatables: metatables})
 }
 
+// Headless will return true if the program is running without a window. Live
+// input is not available and graphics calls do nothing.
+func Headless() bool {
+	return conf.Headless
+}
+
 // RegisterHook will add a lua state load hook
 func RegisterHook(fn LuaLoadHook) {
 	registeredHooks = append(registeredHooks, fn)
 }
 
+// RegisterFrameHook will add a hook that is called after every frame
+func RegisterFrameHook(fn FrameHook) {
+	registeredFrameHooks = append(registeredFrameHooks, fn)
+}
+
+// RegisterUpdateHook will add a hook that is called before every update
+func RegisterUpdateHook(fn UpdateHook) {
+	registeredUpdateHooks = append(registeredUpdateHooks, fn)
+}
+
+// RegisterSaveHook will add a hook that saves the state set by a load hook so
+// that it can be restored when a reload fails
+func RegisterSaveHook(fn SaveHook) {
+	registeredSaveHooks = append(registeredSaveHooks, fn)
+}
+
 // Run starts the lua program
 func Run(entrypoint string) error {
-	if err := glfw.Init(gl.ContextWatcher); err != nil {
+	var err error
+	if conf, err = loadConf(); err != nil {
 		return err
 	}
-	defer glfw.Terminate()
-	win, err := createWindow(conf)
-	if err != nil {
-		return err
+	conf.applyOptions(options)
+	file.SetIdentity(conf.Identity)
+	Debugf("loaded config %+v", conf)
+
+	resetEvents()
+	if options.Events != "" {
+		if err := loadEventScript(options.Events); err != nil {
+			return err
+		}
+	}
+	resetFrameStats()
+	if options.Profile != "" {
+		if profiler, err = newTracer(options.Profile); err != nil {
+			return err
+		}
+		defer func() {
+			if err := profiler.close(); err != nil {
+				Errorf("could not write profile: %v", err)
+			}
+			profiler = nil
+		}()
 	}
 
-	ls := lua.NewState()
-	defer ls.Close()
+	var win *window
+	if conf.Headless {
+		win = newHeadlessWindow(conf)
+		gfx.InitHeadless(int32(conf.Width), int32(conf.Height))
+	} else {
+		if err := glfw.Init(gfx.ContextWatcher); err != nil {
+			return err
+		}
+		defer glfw.Terminate()
+		if win, err = createWindow(conf); err != nil {
+			return err
+		}
+		gfx.InitContext(win.Window)
+		gfx.SetHotReload(options.Watch)
+		if options.Console {
+			devConsole = &console{}
+		}
+		if options.Stats {
+			overlay = &statsOverlay{open: true}
+		}
+	}
+	currentWindow = win
+	ls, err := loadState(entrypoint, win, nil)
+	return gameloop(ls, win, entrypoint, err)
+}
 
-	gfx.InitContext(win.Window)
-	importGlobals(ls, win.Window)
-	importModules(ls)
-	runHooks(ls, win.Window)
+// loadState will create a new lua state with all the modules imported and run
+// the entrypoint and onload in it. If a previous state is given, it is being
+// replaced by a reload, so onreload is called with its globals. A state that
+// fails to load is still returned with the error so that its onerror handler can
+// be used, it is not closed because callbacks may still be bound to it.
+func loadState(entrypoint string, win *window, previous *lua.LState) (*lua.LState, error) {
+	ls := newState(win)
+	builtins := globalNames(ls)
 
+	Infof("running %v", entrypoint)
 	entryfile, err := file.Open(entrypoint)
 	if err != nil {
-		return err
+		return ls, err
 	}
+	defer entryfile.Close()
 
 	if fn, err := ls.Load(entryfile, entrypoint); err != nil {
-		return err
+		return ls, err
 	} else {
 		ls.Push(fn)
 		if err := ls.PCall(0, lua.MultRet, nil); err != nil {
-			return err
+			return ls, err
 		}
 	}
 
 	if load := ls.GetGlobal("onload"); load != lua.LNil {
 		if err := ls.CallByParam(lua.P{Fn: load, Protect: true}); err != nil {
-			return err
+			return ls, err
 		}
 	}
 
-	return gameloop(ls, win)
+	if previous == nil {
+		return ls, nil
+	}
+	if reload := ls.GetGlobal("onreload"); reload != lua.LNil {
+		state := copyGlobals(previous, ls, builtins)
+		if err := ls.CallByParam(lua.P{Fn: reload, Protect: true}, state); err != nil {
+			return ls, err
+		}
+	}
+	return ls, nil
 }
 
-func importGlobals(ls *lua.LState, win *glfw.Window) {
+// newState will create a lua state with all the globals and modules imported and
+// the load hooks run, ready for a program to be run in it.
+func newState(win *window) *lua.LState {
+	ls := lua.NewState()
+	win.bind(ls)
+	importGlobals(ls, win)
+	importModules(ls)
+	installRequire(ls)
+	runHooks(ls, win.Window)
+	if devConsole != nil {
+		devConsole.bind(ls)
+	}
+	return ls
+}
+
+func importGlobals(ls *lua.LState, win *window) {
 	ls.SetGlobal("getfps", ls.NewFunction(func(L *lua.LState) int {
 		L.Push(lua.LNumber(fps))
 		return 1
@@ -102,6 +216,9 @@ func importGlobals(ls *lua.LState, win *glfw.Window) {
 
 func importModules(ls *lua.LState) {
 	for _, mod := range registeredModules {
+		if !conf.Modules[mod.name] {
+			continue
+		}
 		newMod := ls.NewTable()
 		ls.SetFuncs(newMod, mod.functions)
 		for tablename, metatable := range mod.metatables {
@@ -123,27 +240,141 @@ func runHooks(ls *lua.LState, win *glfw.Window) {
 // loop. As such this function should be put as the last call in your main function.
 // update and draw will be called synchronously because calls to OpenGL that are
 // not on the main thread will crash your program.
-func gameloop(luaState *lua.LState, win window) error {
+//
+// If a fixed timestep is configured, update will be called with the timestep as
+// many times as the elapsed time allows, up to MaxSteps per frame, and draw will
+// be passed how far between updates the frame is so that it can interpolate.
+func gameloop(luaState *lua.LState, win *window, entrypoint string, loadErr error) error {
+	defer func() {
+		if luaState != nil {
+			luaState.Close()
+		}
+	}()
+
+	var fileWatcher *watcher
+	if options.Watch && !Replaying() {
+		fileWatcher = newWatcher(".")
+	}
+
+	var screen *errorScreen
+	if loadErr != nil {
+		var err error
+		if screen, err = handleError(luaState, win, loadErr); err != nil {
+			return err
+		}
+	}
+
+	var accumulator float32
 	for !win.ShouldClose() {
-		if update := luaState.GetGlobal("update"); update != lua.LNil {
-			dt := lua.LNumber(step())
-			if err := luaState.CallByParam(lua.P{Fn: update, Protect: true}, dt); err != nil {
+		if fileWatcher != nil {
+			newState, err := reload(luaState, win, entrypoint, fileWatcher.changes())
+			if err != nil {
+				if screen, err = handleError(nil, win, err); err != nil {
+					return err
+				}
+			} else if newState != luaState {
+				luaState, screen = newState, nil
+			}
+		}
+
+		dt, err := frameStep()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if conf.MaxDT > 0 && dt > conf.MaxDT {
+			dt = conf.MaxDT
+		}
+
+		if screen != nil {
+			screen.draw()
+			takeReportedError() // errors from the broken program are ignored
+		} else {
+			err := runFrame(luaState, win, dt, &accumulator)
+			if err == nil {
+				err = takeReportedError()
+			}
+			if err != nil {
+				if screen, err = handleError(luaState, win, err); err != nil {
+					return err
+				}
+			}
+			for _, fn := range registeredFrameHooks {
+				fn()
+			}
+		}
+
+		if !Replaying() {
+			limitFrame(conf.FPS)
+		}
+		if !conf.Headless {
+			glfw.PollEvents()
+		}
+	}
+	return nil
+}
+
+// runFrame will update and draw a single frame
+func runFrame(luaState *lua.LState, win *window, dt float32, accumulator *float32) error {
+	frameStart := time.Now()
+	defer endFrame(frameStart)
+
+	alpha := float32(1)
+	updateStart := time.Now()
+	if conf.Timestep > 0 {
+		*accumulator += dt
+		for steps := 0; *accumulator >= conf.Timestep; steps++ {
+			if steps >= conf.MaxSteps {
+				*accumulator = 0 // drop the time we cannot catch up on
+				break
+			}
+			if err := callUpdate(luaState, conf.Timestep); err != nil {
 				return err
 			}
+			*accumulator -= conf.Timestep
 		}
-		if win.active {
+		alpha = *accumulator / conf.Timestep
+	} else if err := callUpdate(luaState, dt); err != nil {
+		return err
+	}
+	frameTimes.Update = traceSpan("update", updateStart)
+
+	if win.active {
+		drawStart := time.Now()
+		if !gfx.IsHeadless() {
 			color := gfx.GetBackgroundColor()
 			gfx.Clear(color[0], color[1], color[2], color[3])
-			gfx.Origin()
-			if draw := luaState.GetGlobal("draw"); draw != lua.LNil {
-				if err := luaState.CallByParam(lua.P{Fn: draw, Protect: true}); err != nil {
-					return err
-				}
+		}
+		gfx.Origin()
+		if draw := luaState.GetGlobal("draw"); draw != lua.LNil {
+			if err := luaState.CallByParam(lua.P{Fn: draw, Protect: true}, lua.LNumber(alpha)); err != nil {
+				return err
 			}
-			gfx.Present()
-			win.SwapBuffers()
 		}
-		glfw.PollEvents()
+		frameTimes.Draw = traceSpan("draw", drawStart)
+		if devConsole != nil {
+			devConsole.draw()
+		}
+		if overlay != nil {
+			overlay.draw()
+		}
+		presentStart := time.Now()
+		gfx.Present()
+		win.SwapBuffers()
+		traceSpan("present", presentStart)
+	}
+	return nil
+}
+
+func callUpdate(luaState *lua.LState, dt float32) error {
+	for _, fn := range registeredUpdateHooks {
+		if err := fn(dt); err != nil {
+			return err
+		}
+	}
+	if update := luaState.GetGlobal("update"); update != lua.LNil {
+		return luaState.CallByParam(lua.P{Fn: update, Protect: true}, lua.LNumber(dt))
 	}
 	return nil
 }
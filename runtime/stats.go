@@ -0,0 +1,126 @@
+package runtime
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/goxjs/glfw"
+
+	"github.com/tanema/amore/gfx"
+)
+
+const (
+	statsToggle  = glfw.KeyF3
+	statsWidth   = 240
+	statsPadding = 8
+)
+
+// FrameStats are the times spent in the phases of a frame
+type FrameStats struct {
+	Update  time.Duration // time spent in update, including all fixed steps
+	Draw    time.Duration // time spent in draw
+	GCCount int           // garbage collections that finished during the frame
+	GCPause time.Duration // time the program was paused by those collections
+}
+
+// statsOverlay shows the stats of the last frame over the top of the game
+type statsOverlay struct {
+	open bool
+}
+
+var (
+	frameTimes   FrameStats    // stats of the frame being run
+	lastFrame    FrameStats    // stats of the last finished frame
+	gcStats      debug.GCStats // reused so that reading it every frame does not allocate
+	gcCount      int64         // garbage collections when the last frame finished
+	gcPauseTotal time.Duration // total gc pause when the last frame finished
+	overlay      *statsOverlay
+)
+
+// GetFrameStats will return the stats of the last finished frame. The counts of
+// graphics work done in the frame are available from gfx.GetStats.
+func GetFrameStats() FrameStats {
+	return lastFrame
+}
+
+// StatsKey will pass a key event to the stats overlay. It returns true if the
+// overlay used the event and it should not be passed on to the program.
+func StatsKey(key glfw.Key, action glfw.Action) bool {
+	if overlay == nil || key != statsToggle {
+		return false
+	}
+	if action == glfw.Press {
+		overlay.open = !overlay.open
+	}
+	return true
+}
+
+// resetFrameStats will clear the stats for a new run so that the first frame
+// does not include collections from before it started.
+func resetFrameStats() {
+	debug.ReadGCStats(&gcStats)
+	gcCount, gcPauseTotal = gcStats.NumGC, gcStats.PauseTotal
+	frameTimes, lastFrame = FrameStats{}, FrameStats{}
+}
+
+// traceSpan will return the time since start and add it to the profile as a
+// phase of the frame with the name if profiling.
+func traceSpan(name string, start time.Time) time.Duration {
+	end := time.Now()
+	if profiler != nil {
+		profiler.span(name, 1, start, end)
+	}
+	return end.Sub(start)
+}
+
+// endFrame will finish the stats of the frame that started at start and add it to
+// the profile if profiling. The gc stats are read without stopping the world, unlike
+// the memory stats, so that measuring a frame does not add a pause to it.
+func endFrame(start time.Time) {
+	debug.ReadGCStats(&gcStats)
+	frameTimes.GCCount = int(gcStats.NumGC - gcCount)
+	frameTimes.GCPause = gcStats.PauseTotal - gcPauseTotal
+	if profiler != nil {
+		profiler.collections(&gcStats, gcCount)
+		traceSpan("frame", start)
+		profiler.frameStats(gfx.GetStats())
+	}
+	gcCount, gcPauseTotal = gcStats.NumGC, gcStats.PauseTotal
+	lastFrame, frameTimes = frameTimes, FrameStats{}
+}
+
+// draw will draw the stats of the last frame in the top right corner of the screen
+func (o *statsOverlay) draw() {
+	if !o.open {
+		return
+	}
+
+	stats := gfx.GetStats()
+	lines := []string{
+		fmt.Sprintf("fps: %v", fps),
+		fmt.Sprintf("update: %.2fms", ms(lastFrame.Update)),
+		fmt.Sprintf("draw: %.2fms", ms(lastFrame.Draw)),
+		fmt.Sprintf("gc: %v (%.2fms)", lastFrame.GCCount, ms(lastFrame.GCPause)),
+		fmt.Sprintf("draw calls: %v", stats.DrawCalls),
+		fmt.Sprintf("vertices: %v", stats.Vertices),
+		fmt.Sprintf("texture binds: %v", stats.TextureBinds),
+		fmt.Sprintf("shader switches: %v", stats.ShaderSwitches),
+		fmt.Sprintf("canvas switches: %v", stats.CanvasSwitches),
+	}
+
+	drawOverlay(func() {
+		lineHeight := gfx.GetFont().GetHeight()
+		x := gfx.GetWidth() - statsWidth - statsPadding
+		gfx.SetColor(0, 0, 0, 0.7)
+		gfx.Rect("fill", x, statsPadding, statsWidth, lineHeight*float32(len(lines))+statsPadding*2)
+		white := [][]float32{{1, 1, 1, 1}}
+		for i, line := range lines {
+			gfx.Print([]string{line}, white, x+statsPadding, statsPadding*2+lineHeight*float32(i))
+		}
+	})
+}
+
+func ms(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
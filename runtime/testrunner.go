@@ -0,0 +1,459 @@
+package runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/file"
+	"github.com/tanema/amore/gfx"
+	"github.com/tanema/amore/gfx/softgl"
+)
+
+// TestOptions changes how tests are run
+type TestOptions struct {
+	Match  string // only run tests with a name containing Match
+	Render bool   // render with a software gl context instead of headless
+	Update bool   // write rendered images over the golden images
+}
+
+// TestResult is the outcome of a single test function in a test file. A test file
+// that fails to load is reported as a failed result with no name. A skipped test
+// has the reason it was skipped as its message.
+type TestResult struct {
+	File     string
+	Name     string
+	Passed   bool
+	Skipped  bool
+	Message  string
+	Trace    string
+	Duration time.Duration
+}
+
+// testRunner runs a test function of a test file in a fresh lua state
+type testRunner struct {
+	win         *window
+	ls          *lua.LState
+	accumulator float32
+	skipped     string
+}
+
+var (
+	currentTest *testRunner
+	testOptions TestOptions
+
+	testFunctions = LuaFuncs{
+		"equal":    testEqual,
+		"notequal": testNotEqual,
+		"near":     testNear,
+		"istrue":   testIsTrue,
+		"isfalse":  testIsFalse,
+		"isnil":    testIsNil,
+		"notnil":   testNotNil,
+		"errors":   testErrors,
+		"fail":     testFail,
+		"skip":     testSkip,
+		"image":    testImage,
+		"frames":   testFrames,
+		"event":    testEvent,
+		"press":    testPress,
+		"release":  testRelease,
+		"text":     testText,
+	}
+)
+
+// RunTests will run every global function with a name starting with test in each
+// of the test files. Each test is run headless in a fresh lua state with all of
+// the modules loaded and the test file run, so tests cannot affect each other.
+// A test module is added with assertions and functions to simulate frames and
+// input. Each test gets an empty temporary save directory that is removed after
+// it, so tests cannot overwrite real saves or see files written by other tests.
+// When rendering, graphics are drawn with a software gl context so that
+// test.image can compare them to golden images.
+func RunTests(paths []string, opts TestOptions) ([]TestResult, error) {
+	var err error
+	if conf, err = loadConf(); err != nil {
+		return nil, err
+	}
+	for name := range conf.Modules {
+		conf.Modules[name] = true
+	}
+	conf.applyOptions(Options{Headless: true})
+	file.SetIdentity(conf.Identity + "-test")
+	testOptions = opts
+	if opts.Render {
+		if err := softgl.Init(int32(conf.Width), int32(conf.Height)); err != nil {
+			return nil, err
+		}
+	} else {
+		gfx.InitHeadless(int32(conf.Width), int32(conf.Height))
+	}
+
+	win := newHeadlessWindow(conf)
+	win.active = true
+	currentWindow = win
+
+	results := []TestResult{}
+	for _, path := range paths {
+		names, err := testNames(path, win)
+		if err != nil {
+			msg, trace := splitError(err)
+			results = append(results, TestResult{File: path, Message: msg, Trace: trace})
+			continue
+		}
+		for _, name := range names {
+			if strings.Contains(name, opts.Match) {
+				results = append(results, runTest(path, name, win))
+			}
+		}
+	}
+	return results, nil
+}
+
+// testNames will load the test file and return the names of all its test functions
+func testNames(path string, win *window) ([]string, error) {
+	removeSaveDir, err := useTempSaveDir()
+	if err != nil {
+		return nil, err
+	}
+	defer removeSaveDir()
+	runner := &testRunner{win: win}
+	if err := runner.load(path); err != nil {
+		return nil, err
+	}
+	defer runner.ls.Close()
+
+	names := []string{}
+	runner.ls.G.Global.ForEach(func(key, value lua.LValue) {
+		if _, ok := value.(*lua.LFunction); ok && strings.HasPrefix(key.String(), "test") {
+			names = append(names, key.String())
+		}
+	})
+	sort.Strings(names)
+	return names, nil
+}
+
+func runTest(path, name string, win *window) TestResult {
+	start := time.Now()
+	result := TestResult{File: path, Name: name}
+	runner := &testRunner{win: win}
+	removeSaveDir, err := useTempSaveDir()
+	if err == nil {
+		defer removeSaveDir()
+		err = runner.load(path)
+	}
+	if err == nil {
+		err = runner.ls.CallByParam(lua.P{Fn: runner.ls.GetGlobal(name), Protect: true})
+		if reported := takeReportedError(); err == nil {
+			err = reported
+		}
+		runner.ls.Close()
+	}
+	result.Duration = time.Since(start)
+	if runner.skipped != "" {
+		result.Skipped, result.Message = true, runner.skipped
+	} else if err != nil {
+		result.Message, result.Trace = splitError(err)
+	} else {
+		result.Passed = true
+	}
+	return result
+}
+
+// useTempSaveDir will point the save directory at a new empty temporary directory
+// and return a function that removes it.
+func useTempSaveDir() (func(), error) {
+	dir, err := ioutil.TempDir("", file.GetIdentity()+"-")
+	if err != nil {
+		return nil, fmt.Errorf("could not create a save directory for the test: %v", err)
+	}
+	file.SetSaveDirectory(dir)
+	return func() {
+		file.SetSaveDirectory("")
+		os.RemoveAll(dir)
+	}, nil
+}
+
+// load will create a fresh state with the test module and run the test file in it
+func (runner *testRunner) load(path string) error {
+	resetEvents()
+	resetFrameStats()
+	runner.ls = newState(runner.win)
+	currentTest = runner
+	runner.ls.SetGlobal("test", runner.ls.SetFuncs(runner.ls.NewTable(), testFunctions))
+
+	testFile, err := file.Open(path)
+	if err != nil {
+		runner.ls.Close()
+		return err
+	}
+	defer testFile.Close()
+	fn, err := runner.ls.Load(testFile, path)
+	if err == nil {
+		runner.ls.Push(fn)
+		err = runner.ls.PCall(0, lua.MultRet, nil)
+	}
+	if err != nil {
+		runner.ls.Close()
+	}
+	return err
+}
+
+// testFailf will fail the test with the message, prefixed by the message given to
+// the assertion if there is one.
+func testFailf(ls *lua.LState, msgArg int, format string, args ...interface{}) int {
+	if msg := ls.OptString(msgArg, ""); msg != "" {
+		ls.RaiseError("%v: %v", msg, fmt.Sprintf(format, args...))
+	} else {
+		ls.RaiseError(format, args...)
+	}
+	return 0
+}
+
+func testEqual(ls *lua.LState) int {
+	actual, expected := ls.CheckAny(1), ls.CheckAny(2)
+	if !valuesEqual(ls, actual, expected) {
+		return testFailf(ls, 3, "expected %v, got %v", describe(ls, expected), describe(ls, actual))
+	}
+	return 0
+}
+
+func testNotEqual(ls *lua.LState) int {
+	actual, expected := ls.CheckAny(1), ls.CheckAny(2)
+	if valuesEqual(ls, actual, expected) {
+		return testFailf(ls, 3, "expected a value other than %v", describe(ls, expected))
+	}
+	return 0
+}
+
+func testNear(ls *lua.LState) int {
+	actual, expected := ls.CheckNumber(1), ls.CheckNumber(2)
+	epsilon := ls.OptNumber(3, 1e-6)
+	if math.Abs(float64(actual-expected)) > float64(epsilon) {
+		return testFailf(ls, 4, "expected %v within %v, got %v", expected, epsilon, actual)
+	}
+	return 0
+}
+
+func testIsTrue(ls *lua.LState) int {
+	if !lua.LVAsBool(ls.Get(1)) {
+		return testFailf(ls, 2, "expected a true value, got %v", describe(ls, ls.Get(1)))
+	}
+	return 0
+}
+
+func testIsFalse(ls *lua.LState) int {
+	if lua.LVAsBool(ls.Get(1)) {
+		return testFailf(ls, 2, "expected a false value, got %v", describe(ls, ls.Get(1)))
+	}
+	return 0
+}
+
+func testIsNil(ls *lua.LState) int {
+	if ls.Get(1) != lua.LNil {
+		return testFailf(ls, 2, "expected nil, got %v", describe(ls, ls.Get(1)))
+	}
+	return 0
+}
+
+func testNotNil(ls *lua.LState) int {
+	if ls.Get(1) == lua.LNil {
+		return testFailf(ls, 2, "expected a value, got nil")
+	}
+	return 0
+}
+
+// testErrors will call the function and fail if it does not raise an error. If a
+// string is given the error message must contain it.
+func testErrors(ls *lua.LState) int {
+	fn := ls.CheckFunction(1)
+	contains := ls.OptString(2, "")
+	err := ls.CallByParam(lua.P{Fn: fn, Protect: true})
+	if err == nil {
+		return testFailf(ls, 3, "expected an error")
+	}
+	if msg, _ := splitError(err); !strings.Contains(msg, contains) {
+		return testFailf(ls, 3, "expected an error containing %q, got %q", contains, msg)
+	}
+	return 0
+}
+
+func testFail(ls *lua.LState) int {
+	ls.RaiseError("%v", ls.OptString(1, "failed"))
+	return 0
+}
+
+// testSkip will stop the test and report it as skipped with the reason
+func testSkip(ls *lua.LState) int {
+	currentTest.skipped = ls.OptString(1, "skipped")
+	ls.RaiseError("skipped: %v", currentTest.skipped)
+	return 0
+}
+
+// testImage will call the draw function with a canvas of w x h bound and compare
+// what was drawn with the golden png image at the path, with a tolerance for each
+// color channel that defaults to 0.01. The number of pixels that may differ by
+// more than the tolerance defaults to 0, and is a ratio of all the pixels if it is
+// less than 1. The test is skipped if there is no gl context to render with.
+//
+//	test.image("golden/circle.png", 64, 64, function()
+//	  gfx.circle("fill", 32, 32, 20)
+//	end, 0.01, 0.005)
+func testImage(ls *lua.LState) int {
+	path := ls.CheckString(1)
+	width, height := ls.CheckInt(2), ls.CheckInt(3)
+	fn := ls.CheckFunction(4)
+	tolerance := float32(ls.OptNumber(5, 0.01))
+	maxDiff := float64(ls.OptNumber(6, 0))
+	if maxDiff < 0 {
+		ls.ArgError(6, "the number of differing pixels cannot be negative")
+	} else if maxDiff < 1 {
+		maxDiff = math.Floor(maxDiff * float64(width*height))
+	}
+	if gfx.IsHeadless() {
+		currentTest.skipped = "rendering images needs moony test --gl"
+		ls.RaiseError("skipped: %v", currentTest.skipped)
+	}
+
+	var drawErr error
+	pixels, err := gfx.RenderImage(int32(width), int32(height), func() {
+		drawErr = ls.CallByParam(lua.P{Fn: fn, Protect: true})
+	})
+	if drawErr != nil {
+		msg, _ := splitError(drawErr)
+		ls.RaiseError("%v", msg)
+	} else if err != nil {
+		ls.RaiseError("%v", err)
+	}
+	if err := gfx.CompareGolden(pixels, path, tolerance, int(maxDiff), testOptions.Update); err != nil {
+		return testFailf(ls, 7, "%v", err)
+	}
+	return 0
+}
+
+// testFrames will simulate frames of the gameloop, calling update with the fixed dt,
+// which defaults to the configured timestep or 1/60, then draw. Any pushed input
+// events are fed at the start of the first frame.
+func testFrames(ls *lua.LState) int {
+	count := ls.OptInt(1, 1)
+	defaultDT := conf.Timestep
+	if defaultDT <= 0 {
+		defaultDT = 1.0 / 60.0
+	}
+	dt := float32(ls.OptNumber(2, lua.LNumber(defaultDT)))
+	for i := 0; i < count; i++ {
+		frameCount++
+		feedEvents()
+		err := runFrame(ls, currentTest.win, dt, &currentTest.accumulator)
+		if err == nil {
+			err = takeReportedError()
+		}
+		if err != nil {
+			msg, _ := splitError(err)
+			ls.RaiseError("frame %v: %v", frameCount, msg)
+		}
+		for _, fn := range registeredFrameHooks {
+			fn()
+		}
+	}
+	return 0
+}
+
+// testEvent will push an input event to be fed at the start of the next frame. The
+// modifiers are an optional table of names followed by any values.
+func testEvent(ls *lua.LState) int {
+	event := Event{
+		Device:    ls.CheckString(1),
+		Button:    ls.CheckString(2),
+		Action:    ls.CheckString(3),
+		Modifiers: []string{},
+	}
+	if mods, ok := ls.Get(4).(*lua.LTable); ok {
+		mods.ForEach(func(_, value lua.LValue) {
+			event.Modifiers = append(event.Modifiers, value.String())
+		})
+	}
+	for i := 5; i <= ls.GetTop(); i++ {
+		event.Values = append(event.Values, float64(ls.CheckNumber(i)))
+	}
+	if err := PushEvent(event); err != nil {
+		ls.RaiseError("%v", err)
+	}
+	return 0
+}
+
+// RegisterTestFunction will add a function to the test module so that other
+// packages can provide ways to simulate thier own input or state in tests.
+func RegisterTestFunction(name string, fn lua.LGFunction) {
+	testFunctions[name] = fn
+}
+
+func testPress(ls *lua.LState) int {
+	return testPushButton(ls, "press")
+}
+
+func testRelease(ls *lua.LState) int {
+	return testPushButton(ls, "release")
+}
+
+func testPushButton(ls *lua.LState, action string) int {
+	if err := PushEvent(Event{Device: ls.CheckString(1), Button: ls.CheckString(2), Action: action, Modifiers: []string{}}); err != nil {
+		ls.RaiseError("%v", err)
+	}
+	return 0
+}
+
+func testText(ls *lua.LState) int {
+	PushEvent(Event{Device: "text", Button: ls.CheckString(1), Action: "input"})
+	return 0
+}
+
+// valuesEqual will compare two values, tables are compared by thier contents
+func valuesEqual(ls *lua.LState, a, b lua.LValue) bool {
+	tableA, okA := a.(*lua.LTable)
+	tableB, okB := b.(*lua.LTable)
+	if !okA || !okB || tableA == tableB {
+		return ls.Equal(a, b)
+	}
+	equal := tableA.Len() == tableB.Len()
+	count := 0
+	tableA.ForEach(func(key, value lua.LValue) {
+		count++
+		equal = equal && valuesEqual(ls, value, tableB.RawGet(key))
+	})
+	tableB.ForEach(func(key, value lua.LValue) { count-- })
+	return equal && count == 0
+}
+
+// describe will format a value for a failure message. Nested tables are only
+// described a few levels deep so that cycles do not recurse forever.
+func describe(ls *lua.LState, value lua.LValue) string {
+	return describeDepth(ls, value, 3)
+}
+
+func describeDepth(ls *lua.LState, value lua.LValue, depth int) string {
+	switch val := value.(type) {
+	case lua.LString:
+		return strconv.Quote(string(val))
+	case *lua.LTable:
+		if ls.GetMetaField(val, "__tostring") != lua.LNil {
+			return ls.ToStringMeta(val).String()
+		} else if depth <= 0 {
+			return "{...}"
+		}
+		parts := []string{}
+		val.ForEach(func(key, value lua.LValue) {
+			parts = append(parts, fmt.Sprintf("%v = %v", describeDepth(ls, key, depth-1), describeDepth(ls, value, depth-1)))
+		})
+		sort.Strings(parts)
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return ls.ToStringMeta(value).String()
+}
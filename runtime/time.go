@@ -9,6 +9,7 @@ var (
 	frames            int       // frames since last update freq
 	previousTime      time.Time // last frame time
 	previousFPSUpdate time.Time // last time fps was updated
+	nextFrame         time.Time // earliest time the next frame should start when limiting
 )
 
 func step() float32 {
@@ -23,3 +24,18 @@ func step() float32 {
 	}
 	return dt
 }
+
+// limitFrame will sleep until enough time has passed since the last frame to
+// keep the frame rate at or below the fps given. An fps of 0 means no limit.
+func limitFrame(fps int) {
+	if fps <= 0 {
+		return
+	}
+	now := time.Now()
+	frameTime := time.Second / time.Duration(fps)
+	if nextFrame.Before(now) {
+		nextFrame = now // we have fallen behind so dont try to catch up
+	}
+	time.Sleep(nextFrame.Sub(now))
+	nextFrame = nextFrame.Add(frameTime)
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 
 	// These are lua wrapped code that will be made accessible to lua
+	_ "github.com/tanema/amore/file/wrap"
 	_ "github.com/tanema/amore/gfx/wrap"
 	_ "github.com/tanema/amore/input"
+	_ "github.com/tanema/amore/timer"
 
 	"github.com/tanema/amore/runtime"
 )
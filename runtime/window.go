@@ -1,49 +1,300 @@
 package runtime
 
 import (
+	"fmt"
+	"image"
+	// All image types have been imported for loading window icons
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
 	"github.com/goxjs/glfw"
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/file"
+	"github.com/tanema/amore/gfx"
 )
 
-type config struct {
-	Title      string
-	Width      int
-	Height     int
-	Resizable  bool
-	Fullscreen bool
-	MouseShown bool
+type window struct {
+	*glfw.Window
+	ls         *lua.LState
+	active     bool
+	closed     bool   // if a headless window should close
+	size       [2]int // w, h of a headless window, which has no glfw window
+	title      string
+	vsync      bool
+	fullscreen bool
+	windowed   [4]int // x, y, w, h of the window before going fullscreen
 }
 
 var (
-	conf = config{
-		Width:  800,
-		Height: 600,
+	currentWindow *window
+
+	windowFunctions = LuaFuncs{
+		"gettitle":         windowGetTitle,
+		"settitle":         windowSetTitle,
+		"getsize":          windowGetSize,
+		"setsize":          windowSetSize,
+		"getposition":      windowGetPosition,
+		"setposition":      windowSetPosition,
+		"getfullscreen":    windowGetFullscreen,
+		"setfullscreen":    windowSetFullscreen,
+		"getvsync":         windowGetVsync,
+		"setvsync":         windowSetVsync,
+		"seticon":          windowSetIcon,
+		"iscursorvisible":  windowIsCursorVisible,
+		"setcursorvisible": windowSetCursorVisible,
+		"iscursorlocked":   windowIsCursorLocked,
+		"setcursorlocked":  windowSetCursorLocked,
 	}
 )
 
-type window struct {
-	*glfw.Window
-	active bool
-}
+func createWindow(conf config) (*window, error) {
+	newWin := &window{active: true, title: conf.Title}
 
-func createWindow(conf config) (window, error) {
-	newWin := window{active: true}
+	if conf.Resizable {
+		glfw.WindowHint(glfw.Resizable, 1)
+	} else {
+		glfw.WindowHint(glfw.Resizable, 0)
+	}
+	glfw.WindowHint(glfw.Samples, conf.Samples)
+
+	var monitor *glfw.Monitor
+	if conf.Fullscreen {
+		monitor = glfw.GetPrimaryMonitor()
+		newWin.fullscreen = true
+		newWin.windowed = [4]int{0, 0, conf.Width, conf.Height}
+	}
 
 	var err error
-	newWin.Window, err = glfw.CreateWindow(conf.Width, conf.Height, conf.Title, nil, nil)
+	newWin.Window, err = glfw.CreateWindow(conf.Width, conf.Height, conf.Title, monitor, nil)
 	if err != nil {
-		return window{}, err
+		return nil, err
 	}
 	newWin.MakeContextCurrent()
-	if conf.Resizable {
-		glfw.WindowHint(glfw.Resizable, 1)
-	} else {
-		glfw.WindowHint(glfw.Resizable, 0)
+	newWin.setVsync(conf.Vsync)
+	if !conf.MouseShown {
+		newWin.SetInputMode(glfw.CursorMode, glfw.CursorHidden)
 	}
-	glfw.WindowHint(glfw.Samples, 4.0)
 	newWin.SetFocusCallback(newWin.focus)
 	newWin.SetIconifyCallback(newWin.iconify)
 	return newWin, nil
 }
 
+// newHeadlessWindow will create a window with no glfw window behind it so that the
+// program can run without a display. It is only active, and so drawn, if drawing
+// has been asked for.
+func newHeadlessWindow(conf config) *window {
+	return &window{
+		active: options.HeadlessDraw,
+		title:  conf.Title,
+		size:   [2]int{conf.Width, conf.Height},
+	}
+}
+
+// ShouldClose will return true if the window has been asked to close
+func (win *window) ShouldClose() bool {
+	if win.Window == nil {
+		return win.closed
+	}
+	return win.Window.ShouldClose()
+}
+
+// SetShouldClose will ask the window to close at the end of the frame
+func (win *window) SetShouldClose(value bool) {
+	if win.Window == nil {
+		win.closed = value
+		return
+	}
+	win.Window.SetShouldClose(value)
+}
+
+// SwapBuffers will show the drawn frame
+func (win *window) SwapBuffers() {
+	if win.Window != nil {
+		win.Window.SwapBuffers()
+	}
+}
+
+// GetSize will return the size of the window
+func (win *window) GetSize() (int, int) {
+	if win.Window == nil {
+		return win.size[0], win.size[1]
+	}
+	return win.Window.GetSize()
+}
+
+// bind will attach the lua state to the window so that resize events can be
+// dispatched to the onresize callback. This needs to be called after the graphics
+// context is initialized so that these callbacks take precedence.
+func (win *window) bind(ls *lua.LState) {
+	win.ls = ls
+	if win.Window == nil {
+		return
+	}
+	win.SetFramebufferSizeCallback(win.framebufferResize)
+	win.SetSizeCallback(win.resize)
+}
+
 func (win *window) focus(w *glfw.Window, focused bool)     { win.active = focused }
 func (win *window) iconify(w *glfw.Window, iconified bool) { win.active = !iconified }
+
+func (win *window) framebufferResize(w *glfw.Window, width, height int) {
+	gfx.SetViewportSize(int32(width), int32(height))
+}
+
+func (win *window) resize(w *glfw.Window, width, height int) {
+	if win.ls == nil {
+		return
+	}
+	if callback := win.ls.GetGlobal("onresize"); callback != lua.LNil {
+		if err := win.ls.CallByParam(lua.P{Fn: callback, Protect: true}, lua.LNumber(width), lua.LNumber(height)); err != nil {
+			ReportError(err)
+		}
+	}
+}
+
+func (win *window) setVsync(vsync bool) {
+	win.vsync = vsync
+	if win.Window == nil {
+		return
+	}
+	if vsync {
+		glfw.SwapInterval(1)
+	} else {
+		glfw.SwapInterval(0)
+	}
+}
+
+func (win *window) loadIcon(path string) error {
+	iconFile, err := file.Open(path)
+	if err != nil {
+		return err
+	}
+	defer iconFile.Close()
+	img, _, err := image.Decode(iconFile)
+	if err != nil {
+		return fmt.Errorf("could not decode icon %v: %v", path, err)
+	}
+	win.setIcon(img)
+	return nil
+}
+
+func windowGetTitle(ls *lua.LState) int {
+	ls.Push(lua.LString(currentWindow.title))
+	return 1
+}
+
+func windowSetTitle(ls *lua.LState) int {
+	currentWindow.title = ls.CheckString(1)
+	if currentWindow.Window != nil {
+		currentWindow.SetTitle(currentWindow.title)
+	}
+	return 0
+}
+
+func windowGetSize(ls *lua.LState) int {
+	w, h := currentWindow.GetSize()
+	ls.Push(lua.LNumber(w))
+	ls.Push(lua.LNumber(h))
+	return 2
+}
+
+func windowSetSize(ls *lua.LState) int {
+	if currentWindow.Window == nil {
+		currentWindow.size = [2]int{ls.CheckInt(1), ls.CheckInt(2)}
+		return 0
+	}
+	currentWindow.SetSize(ls.CheckInt(1), ls.CheckInt(2))
+	return 0
+}
+
+func windowGetPosition(ls *lua.LState) int {
+	var x, y int
+	if currentWindow.Window != nil {
+		x, y = currentWindow.GetPos()
+	}
+	ls.Push(lua.LNumber(x))
+	ls.Push(lua.LNumber(y))
+	return 2
+}
+
+func windowSetPosition(ls *lua.LState) int {
+	if currentWindow.Window != nil {
+		currentWindow.SetPos(ls.CheckInt(1), ls.CheckInt(2))
+	}
+	return 0
+}
+
+func windowGetFullscreen(ls *lua.LState) int {
+	ls.Push(lua.LBool(currentWindow.fullscreen))
+	return 1
+}
+
+func windowSetFullscreen(ls *lua.LState) int {
+	if currentWindow.Window != nil {
+		currentWindow.setFullscreen(ls.ToBool(1))
+	}
+	return 0
+}
+
+func windowGetVsync(ls *lua.LState) int {
+	ls.Push(lua.LBool(currentWindow.vsync))
+	return 1
+}
+
+func windowSetVsync(ls *lua.LState) int {
+	currentWindow.setVsync(ls.ToBool(1))
+	return 0
+}
+
+func windowSetIcon(ls *lua.LState) int {
+	if currentWindow.Window == nil {
+		ls.Push(lua.LFalse)
+		return 1
+	}
+	ls.Push(lua.LBool(currentWindow.loadIcon(ls.CheckString(1)) == nil))
+	return 1
+}
+
+func windowIsCursorVisible(ls *lua.LState) int {
+	if currentWindow.Window == nil {
+		ls.Push(lua.LFalse)
+		return 1
+	}
+	ls.Push(lua.LBool(currentWindow.GetInputMode(glfw.CursorMode) == glfw.CursorNormal))
+	return 1
+}
+
+func windowSetCursorVisible(ls *lua.LState) int {
+	if currentWindow.Window == nil {
+		return 0
+	}
+	if ls.ToBool(1) {
+		currentWindow.SetInputMode(glfw.CursorMode, glfw.CursorNormal)
+	} else {
+		currentWindow.SetInputMode(glfw.CursorMode, glfw.CursorHidden)
+	}
+	return 0
+}
+
+func windowIsCursorLocked(ls *lua.LState) int {
+	if currentWindow.Window == nil {
+		ls.Push(lua.LFalse)
+		return 1
+	}
+	ls.Push(lua.LBool(currentWindow.GetInputMode(glfw.CursorMode) == glfw.CursorDisabled))
+	return 1
+}
+
+func windowSetCursorLocked(ls *lua.LState) int {
+	if currentWindow.Window == nil {
+		return 0
+	}
+	if ls.ToBool(1) {
+		currentWindow.SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
+	} else {
+		currentWindow.SetInputMode(glfw.CursorMode, glfw.CursorNormal)
+	}
+	return 0
+}
@@ -0,0 +1,12 @@
+package runtime
+
+import (
+	"image"
+)
+
+// setFullscreen is not supported in the browser since fullscreen can only be
+// requested from within a user input event.
+func (win *window) setFullscreen(fullscreen bool) {}
+
+// setIcon is not supported in the browser, the favicon should be used instead.
+func (win *window) setIcon(img image.Image) {}
@@ -0,0 +1,32 @@
+// +build !js
+
+package runtime
+
+import (
+	"image"
+
+	"github.com/goxjs/glfw"
+)
+
+// setFullscreen will move the window onto the primary monitor with the current
+// window size as the resolution, or restore it back to its previous windowed
+// position and size.
+func (win *window) setFullscreen(fullscreen bool) {
+	if fullscreen == win.fullscreen {
+		return
+	}
+	if fullscreen {
+		win.windowed[0], win.windowed[1] = win.GetPos()
+		win.windowed[2], win.windowed[3] = win.GetSize()
+		monitor := glfw.GetPrimaryMonitor()
+		win.SetMonitor(monitor.Monitor, 0, 0, win.windowed[2], win.windowed[3], monitor.GetVideoMode().RefreshRate)
+	} else {
+		win.SetMonitor(nil, win.windowed[0], win.windowed[1], win.windowed[2], win.windowed[3], 0)
+	}
+	win.fullscreen = fullscreen
+}
+
+// setIcon sets the window icon.
+func (win *window) setIcon(img image.Image) {
+	win.SetIcon([]image.Image{img})
+}
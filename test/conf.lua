@@ -0,0 +1,7 @@
+function conf(t)
+  t.identity = "amoretest"
+  t.window.title = "Amore Test"
+  t.window.width = 800
+  t.window.height = 600
+  t.window.resizable = true
+end
@@ -0,0 +1,104 @@
+-- run with moony test test/
+
+function testkeyboardinput()
+  local pressed
+  function oninput(device, button, action)
+    if action == "press" then
+      pressed = button
+    end
+  end
+
+  test.press("keyboard", "space")
+  test.frames(1)
+  test.equal(pressed, "space")
+  test.istrue(input.isdown("keyboard", "space"))
+
+  test.release("keyboard", "space")
+  test.frames(1)
+  test.isfalse(input.isdown("keyboard", "space"))
+end
+
+function testtextinput()
+  local typed = ""
+  function ontextinput(text)
+    typed = typed .. text
+  end
+
+  test.text("hello")
+  test.frames(1)
+  test.equal(typed, "hello")
+end
+
+-- held actions are released when they are unbound or their bindings replaced
+function testactionreleasedonunbind()
+  local events = {}
+  function onaction(name, event, value)
+    table.insert(events, name .. " " .. event .. " " .. value)
+  end
+  input.bind("jumpunbind", {"keyboard", "space"})
+  test.press("keyboard", "space")
+  test.frames(1)
+  input.bind("jumpunbind", {"keyboard", "space"}, {"keyboard", "w"})
+  input.bind("jumpunbind", {"keyboard", "w"})
+  input.addbinding("jumpunbind", {"keyboard", "space"})
+  input.addbinding("jumpunbind", {"keyboard", "s"})
+  input.unbind("jumpunbind")
+  test.release("keyboard", "space")
+  test.frames(1)
+  test.equal(events, {
+    "jumpunbind press 1",
+    "jumpunbind release 0", "jumpunbind press 1",
+    "jumpunbind release 0",
+    "jumpunbind press 1",
+    "jumpunbind release 0",
+  })
+end
+
+function testtimers()
+  local fired = false
+  timer.after(0.5, function() fired = true end)
+  test.frames(10, 0.01)
+  test.isfalse(fired)
+  test.frames(50, 0.01)
+  test.istrue(fired)
+  test.near(timer.gettime(), 0.6)
+end
+
+function testupdateanddraw()
+  local updates, draws = 0, 0
+  function update(dt) updates = updates + 1 end
+  function draw() draws = draws + 1 end
+
+  test.frames(3)
+  test.equal({updates, draws}, {3, 3})
+end
+
+function testheadlessgraphics()
+  local img = gfx.newimage("icon.png")
+  test.istrue(img:getwidth() > 0)
+  gfx.rectangle("fill", 0, 0, 10, 10)
+  test.equal(gfx.getwidth(), 800)
+end
+
+function testgamepadmapping()
+  input.addmapping("03000000000000000000000000000001,Test Pad,a:b0,leftx:a0,dpup:h0.1,")
+  test.joystick(0, {name = "Test Pad", axes = {1}, buttons = {true}, hats = {1}})
+  test.frames(1)
+  test.equal(input.getgamepads(), {0})
+  test.istrue(input.isgamepaddown(0, "a"))
+  test.istrue(input.isgamepaddown(0, "dpup"))
+  test.near(input.getaxis(0, "leftx"), 1)
+
+  test.joystick(0, nil)
+  test.frames(1)
+  test.equal(input.getgamepads(), {})
+end
+
+-- desktop glfw reports no hats so d-pad mappings never fire there
+function testgamepadwithouthats()
+  input.addmapping("03000000000000000000000000000002,Hatless Pad,a:b0,dpup:h0.1,")
+  test.joystick(0, {name = "Hatless Pad", buttons = {true}})
+  test.frames(1)
+  test.istrue(input.isgamepaddown(0, "a"))
+  test.isfalse(input.isgamepaddown(0, "dpup"))
+end
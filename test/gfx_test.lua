@@ -0,0 +1,454 @@
+-- golden image tests, run with moony test --gl test/
+-- the images in test/golden are written, and rewritten after an intended change,
+-- with moony test --gl --update test/
+-- they were rendered with the mesa 22.3.6 llvmpipe driver (LLVM 15.0.6), other
+-- drivers or versions can rasterize curved and slanted edges differently so the
+-- tests that draw them allow 1% of the pixels to differ
+
+function testcircle()
+  test.image("golden/circle.png", 64, 64, function()
+    gfx.circle("fill", 20, 20, 16)
+    gfx.setcolor(1, 0, 0, 1)
+    gfx.circle("line", 44, 44, 16, 8)
+  end, 0.01, 0.01)
+end
+
+function testarc()
+  test.image("golden/arc.png", 64, 64, function()
+    gfx.arc("fill", 32, 32, 28, 0, math.pi / 2)
+    gfx.setcolor(0, 1, 0, 1)
+    gfx.arc("line", 32, 32, 28, math.pi, math.pi * 1.75, 10)
+  end, 0.01, 0.01)
+end
+
+-- the segments are the argument after the angles
+function testarcsegments()
+  test.image("golden/arc_segments.png", 64, 64, function()
+    gfx.arc("fill", 32, 32, 28, 0, math.pi, 3)
+  end, 0.01, 0.01)
+end
+
+-- the image taken from a canvas is the right way up
+function testcanvasnewimage()
+  test.image("golden/canvas_newimage.png", 64, 64, function()
+    local target = gfx.getcanvas()
+    local canvas = gfx.newcanvas(32, 32)
+    gfx.setcanvas(canvas)
+    gfx.setcolor(1, 0, 0, 1)
+    gfx.rectangle("fill", 0, 0, 32, 8)
+    gfx.setcolor(0, 0, 1, 1)
+    gfx.rectangle("fill", 0, 8, 8, 24)
+    gfx.setcanvas(target)
+    gfx.setcolor(1, 1, 1, 1)
+    canvas:draw(0, 0)
+    canvas:newimage():draw(32, 32)
+  end)
+end
+
+function testellipse()
+  test.image("golden/ellipse.png", 64, 64, function()
+    gfx.ellipse("fill", 32, 20, 28, 12)
+    gfx.setcolor(0, 0, 1, 1)
+    gfx.ellipse("line", 32, 46, 12, 14)
+  end, 0.01, 0.01)
+end
+
+function testpolygon()
+  test.image("golden/polygon.png", 64, 64, function()
+    gfx.polygon("fill", 4, 4, 40, 8, 24, 36)
+    gfx.setcolor(1, 1, 0, 1)
+    gfx.polygon("line", 30, 60, 60, 30, 60, 60)
+  end, 0.01, 0.01)
+end
+
+function testlinemiter()
+  test.image("golden/line_miter.png", 64, 64, function()
+    gfx.setlinewidth(6)
+    gfx.setlinejoin("miter")
+    gfx.line(8, 56, 20, 8, 32, 56, 44, 8, 56, 30)
+  end, 0.01, 0.01)
+end
+
+function testlinebevel()
+  test.image("golden/line_bevel.png", 64, 64, function()
+    gfx.setlinewidth(6)
+    gfx.setlinejoin("bevel")
+    gfx.line(8, 56, 20, 8, 32, 56, 44, 8, 56, 30)
+  end, 0.01, 0.01)
+end
+
+function testlinesharpangle()
+  test.image("golden/line_sharp.png", 64, 64, function()
+    gfx.setlinewidth(4)
+    gfx.line(8, 8, 56, 32, 8, 34)
+  end, 0.01, 0.01)
+end
+
+function teststencil()
+  test.image("golden/stencil.png", 64, 64, function()
+    gfx.stencil(function()
+      gfx.circle("fill", 32, 32, 20)
+    end, "replace", 1)
+    gfx.setstenciltest("greater", 0)
+    gfx.rectangle("fill", 0, 0, 64, 32)
+    gfx.setstenciltest("equal", 0)
+    gfx.setcolor(1, 0, 0, 1)
+    gfx.rectangle("fill", 0, 32, 64, 32)
+    gfx.setstenciltest()
+  end, 0.01, 0.01)
+end
+
+function testblendmodes()
+  local modes = {"alpha", "additive", "subtractive", "multiplicative", "screen", "replace", "premultiplied"}
+  test.image("golden/blend.png", 16 * #modes, 16, function()
+    for i, mode in ipairs(modes) do
+      local x = (i - 1) * 16
+      gfx.setblendmode("alpha")
+      gfx.setcolor(0.2, 0.4, 0.8, 1)
+      gfx.rectangle("fill", x, 0, 16, 16)
+      gfx.setblendmode(mode)
+      gfx.setcolor(0.8, 0.5, 0.2, 0.5)
+      gfx.rectangle("fill", x + 4, 4, 8, 8)
+    end
+  end)
+end
+
+function testtextwrap()
+  test.image("golden/text_wrap.png", 128, 96, function()
+    gfx.printf("the quick brown fox jumps over the lazy dog", 120, "left", 4, 4)
+  end, 0.01, 0.01)
+end
+
+function testtextalign()
+  local text = gfx.newtext(gfx.getfont(), "wrapped and centered text", 140, "center")
+  test.image("golden/text_align.png", 160, 64, function()
+    text:draw(10, 4)
+  end, 0.01, 0.01)
+end
+
+function testspritebatch()
+  local img = gfx.newimage("icon.png")
+  local batch = gfx.newspritebatch(img, 4)
+  batch:add(0, 0, 0, 0.25, 0.25)
+  batch:add(32, 0, 0, 0.25, 0.25)
+  batch:setcolor(1, 0, 0, 1)
+  batch:add(0, 32, 0, 0.25, 0.25)
+  test.equal(batch:getcount(), 3)
+  test.image("golden/spritebatch.png", 64, 64, function()
+    batch:draw()
+  end)
+end
+
+function testmesh()
+  local mesh = gfx.newmesh({
+    {4, 4, 0, 0, 1, 0, 0, 1},
+    {60, 4, 1, 0, 0, 1, 0, 1},
+    {60, 60, 1, 1, 0, 0, 1, 1},
+    {4, 60, 0, 1, 1, 1, 1, 1},
+  }, "fan")
+  test.equal(mesh:getvertexcount(), 4)
+  mesh:setvertex(3, 4, 40, 0, 1, 1, 1, 0, 1)
+  test.image("golden/mesh.png", 64, 64, function()
+    mesh:draw()
+  end)
+end
+
+function testmeshvertexformaterrors()
+  local format = "vertex format attributes should be {name, components}"
+  test.errors(function() gfx.newmesh(3, "fan", "static", {"VertexPosition"}) end, format)
+  test.errors(function() gfx.newmesh(3, "fan", "static", {{"VertexPosition"}}) end, format)
+end
+
+function testmeshvertexmap()
+  local mesh = gfx.newmesh({{0, 0}, {64, 0}, {64, 64}, {0, 64}}, "triangles", "static", {{"VertexPosition", 2}})
+  mesh:setvertexmap({0, 1, 2, 0, 2, 3})
+  mesh:setdrawrange(0, 2)
+  test.image("golden/mesh_vertexmap.png", 64, 64, function()
+    mesh:draw()
+  end)
+end
+
+local function newinstancedspritebatch()
+  local img = gfx.newimage("icon.png")
+  local batch = gfx.newspritebatch(img, 1)
+  batch:add(0, 0, 0, 0.125, 0.125)
+  local instances = gfx.newinstances(16)
+  for i = 0, 15 do
+    instances:setcolor(i / 15, 1 - i / 15, 1, 1)
+    instances:add((i % 4) * 16, math.floor(i / 4) * 16)
+  end
+  test.equal(instances:getcount(), 16)
+  batch:setinstances(instances)
+  return batch
+end
+
+function testinstancedspritebatch()
+  local batch = newinstancedspritebatch()
+  test.image("golden/instanced_spritebatch.png", 64, 64, function()
+    batch:draw()
+  end)
+end
+
+local function newinstancedmesh()
+  local mesh = gfx.newmesh({{0, 0}, {12, 0}, {6, 12}}, "triangles", "static", {{"VertexPosition", 2}})
+  local instances = gfx.newinstances(4)
+  instances:add(8, 8)
+  instances:add(40, 8, math.pi / 4)
+  instances:add(8, 40, 0, 2)
+  instances:setcolor(1, 0, 0, 1)
+  instances:add(40, 40)
+  instances:set(0, 4, 4)
+  mesh:setinstances(instances)
+  return mesh
+end
+
+function testinstancedmesh()
+  local mesh = newinstancedmesh()
+  test.image("golden/instanced_mesh.png", 64, 64, function()
+    mesh:draw()
+  end)
+end
+
+-- instances are expanded on the cpu without instancing support and have to draw
+-- the same as they do on the gpu
+function testinstancedcpu()
+  local batch, mesh = newinstancedspritebatch(), newinstancedmesh()
+  test.image("golden/instanced_spritebatch.png", 64, 64, function()
+    gfx.setinstancing(false)
+    batch:draw()
+  end)
+  test.image("golden/instanced_mesh.png", 64, 64, function()
+    gfx.setinstancing(false)
+    mesh:draw()
+  end)
+end
+
+-- fans, strips and vertex maps are turned into triangles to repeat them on the cpu
+local function drawinstancedmodes()
+  local format = {{"VertexPosition", 2}, {"VertexColor", 4}}
+  local square = {{0, 0, 1, 0, 0, 1}, {12, 0, 0, 1, 0, 1}, {12, 12, 0, 0, 1, 1}, {0, 12, 1, 1, 1, 1}}
+  local fan = gfx.newmesh(square, "fan", "static", format)
+  local strip = gfx.newmesh({{0, 0, 1, 0, 0, 1}, {0, 12, 0, 1, 0, 1}, {6, 0, 0, 0, 1, 1}, {6, 12, 1, 1, 1, 1}, {12, 6, 1, 0, 0, 1}}, "strip", "static", format)
+  local mapped = gfx.newmesh(square, "triangles", "static", format)
+  mapped:setvertexmap({0, 1, 2, 0, 2, 3})
+  mapped:setdrawrange(3, 5)
+  for i, mesh in ipairs({fan, strip, mapped}) do
+    local instances = gfx.newinstances(3)
+    instances:add(4, (i - 1) * 20 + 4)
+    instances:setcolor(0.5, 1, 1, 1)
+    instances:add(24, (i - 1) * 20 + 4, 0, 1.5)
+    instances:add(48, (i - 1) * 20 + 4, math.pi / 8)
+    mesh:setinstances(instances)
+    mesh:draw()
+  end
+end
+
+function testinstancedmodes()
+  test.image("golden/instanced_modes.png", 64, 64, drawinstancedmodes)
+  test.image("golden/instanced_modes.png", 64, 64, function()
+    gfx.setinstancing(false)
+    drawinstancedmodes()
+  end)
+end
+
+function testbatching()
+  local img = gfx.newimage("icon.png")
+  test.image("golden/batching.png", 64, 64, function()
+    for i = 0, 7 do
+      gfx.setcolor(i / 7, 0, 1 - i / 7, 1)
+      gfx.rectangle("fill", i * 8, 0, 8, 8)
+      gfx.push()
+      gfx.translate(i * 8, 8)
+      gfx.circle("fill", 4, 4, 4)
+      gfx.pop()
+    end
+    gfx.setcolor(1, 1, 1, 1)
+    img:draw(0, 16, 0, 0.125, 0.125)
+    img:draw(32, 16, 0, 0.125, 0.125)
+    gfx.setblendmode("additive")
+    gfx.rectangle("fill", 16, 24, 32, 32)
+    gfx.setblendmode("alpha")
+    gfx.print("ab", 0, 40)
+    gfx.setpointsize(4)
+    gfx.points(60, 60)
+  end)
+
+  -- draws with the same texture and state are one draw call
+  function draw()
+    for i = 0, 7 do
+      gfx.setcolor(i / 7, 1, 1, 1)
+      img:draw(i * 8, 0, 0, 0.125, 0.125)
+    end
+  end
+  test.frames(1)
+  test.equal(gfx.getstats().drawcalls, 1, "same texture")
+
+  -- changing the blend mode or shader flushes the draws before it
+  function draw()
+    img:draw(0, 0, 0, 0.125, 0.125)
+    gfx.setblendmode("additive")
+    img:draw(8, 0, 0, 0.125, 0.125)
+    gfx.setblendmode("alpha")
+  end
+  test.frames(1)
+  test.equal(gfx.getstats().drawcalls, 2, "blend mode change")
+
+  local shader = gfx.newshader([[
+vec4 effect(vec4 color, sampler2D texture, vec2 textureCoordinate, vec2 pixcoord) {
+  return texture2D(texture, textureCoordinate) * color;
+}]])
+  function draw()
+    img:draw(0, 0, 0, 0.125, 0.125)
+    gfx.setshader(shader)
+    img:draw(8, 0, 0, 0.125, 0.125)
+    gfx.setshader()
+  end
+  test.frames(1)
+  test.equal(gfx.getstats().drawcalls, 2, "shader change")
+end
+
+-- state set without drawing is kept when headless so that the getters match it
+function testsetstate()
+  gfx.setcolor(1, 0, 0, 0.5)
+  test.equal({gfx.getcolor()}, {1, 0, 0, 0.5})
+  gfx.setcolor(1, 1, 1, 1)
+
+  local canvas = gfx.newcanvas(16, 16)
+  gfx.setcanvas(canvas)
+  test.notnil(gfx.getcanvas())
+  gfx.setcanvas()
+  test.isnil(gfx.getcanvas())
+
+  gfx.setscissor(1, 2, 3, 4)
+  test.equal({gfx.getscissor()}, {1, 2, 3, 4})
+  gfx.setscissor()
+
+  gfx.setstenciltest("equal", 1)
+  test.equal({gfx.getstenciltest()}, {"equal", 1})
+  gfx.setstenciltest()
+  test.equal(gfx.getstenciltest(), "always")
+end
+
+function testatlas()
+  local atlas, err = gfx.newatlas({"icon.png", "icon.png"}, {padding = 2, extrude = 1})
+  test.equal(err, nil)
+  test.equal(#atlas:getnames(), 1)
+  test.equal(#atlas:getpages(), 1)
+  local x, y = atlas:getquad("icon"):getviewport()
+  test.equal(x, 1)
+  test.equal(y, 1)
+  test.equal(atlas:getquad("missing"), nil)
+  test.image("golden/atlas.png", 64, 64, function()
+    atlas:draw("icon", 0, 0, 0, 0.25, 0.25)
+  end)
+end
+
+-- images that do not fit in a page are packed into more pages
+function testatlaspages()
+  local atlas, err = gfx.newatlas("atlas", {size = 48, padding = 2})
+  test.equal(err, nil)
+  test.equal(#atlas:getnames(), 5)
+  test.equal(#atlas:getpages(), 3)
+  local sizes = {square = {40, 40}, tall = {20, 30}, wide = {30, 20}, small = {10, 10}, thin = {24, 8}}
+  for name, size in pairs(sizes) do
+    local _, _, w, h = atlas:getquad("atlas/" .. name):getviewport()
+    test.equal(w, size[1], name)
+    test.equal(h, size[2], name)
+  end
+  test.image("golden/atlas_pages.png", 150, 48, function()
+    for i, page in ipairs(atlas:getpages()) do
+      page:draw((i - 1) * 50, 0)
+    end
+  end)
+end
+
+function testatlastoolarge()
+  local atlas, err = gfx.newatlas({"atlas/small.png", "atlas/square.png"}, {size = 32})
+  test.isnil(atlas)
+  test.equal(err, "atlas/square is larger than the atlas size of 32")
+end
+
+-- a cached atlas is packed again once one of its images changes
+function testatlascache()
+  test.istrue(file.write("atlascache.png", file.read("atlas/small.png")))
+  local atlas = gfx.newatlas("atlascache.png", {cache = "atlascache"})
+  test.istrue(file.exists("atlascache.json"))
+  test.equal(select(3, atlas:getquad("atlascache"):getviewport()), 10)
+  atlas = gfx.newatlas("atlascache.png", {cache = "atlascache"})
+  test.equal(select(3, atlas:getquad("atlascache"):getviewport()), 10, "cached")
+
+  test.istrue(file.write("atlascache.png", file.read("atlas/wide.png")))
+  atlas = gfx.newatlas("atlascache.png", {cache = "atlascache"})
+  local _, _, w, h = atlas:getquad("atlascache"):getviewport()
+  test.equal(w, 30, "changed")
+  test.equal(h, 20, "changed")
+end
+
+function testanimation()
+  local anim = gfx.newgridanimation(gfx.newimage("icon.png"), 120, 111, nil, 0.1)
+  test.equal(anim:getframecount(), 16)
+  local frames, loops = {}, 0
+  anim:onframe(function(frame) table.insert(frames, frame) end)
+  anim:onloop(function() loops = loops + 1 end)
+  anim:addtag("walk", 1, 3, "pingpong")
+  anim:settag("walk")
+  anim:update(0.45)
+  test.equal(table.concat(frames, ","), "2,3,2,1")
+  test.equal(loops, 1)
+  anim:setmode("once")
+  anim:restart()
+  anim:update(1)
+  test.equal(anim:getframe(), 3)
+  test.isfalse(anim:isplaying())
+  anim:setflip(true, false)
+  test.image("golden/animation.png", 64, 64, function()
+    anim:draw(0, 0, 0, 0.25, 0.25)
+  end)
+end
+
+function testanimationbatch()
+  local img = gfx.newimage("icon.png")
+  local anim = gfx.newgridanimation(img, 120, 111, nil, 0.1)
+  local batch = gfx.newspritebatch(img, 2)
+  anim:addto(batch, 0, 0)
+  anim:setin(batch, 0, 8, 8)
+  test.equal(batch:getcount(), 1)
+  test.errors(function() anim:setin(batch, 1) end, "invalid sprite index 1")
+  test.errors(function() anim:setin(batch, -1) end, "invalid sprite index -1")
+  local other = gfx.newspritebatch(gfx.newimage("icon.png"), 1)
+  test.errors(function() anim:addto(other) end, "does not have the texture of the animation")
+  test.errors(function() anim:setin(other, 0) end, "does not have the texture of the animation")
+  test.equal(other:getcount(), 0)
+end
+
+-- callbacks run in the state that updates the animation, not the one that set them
+function testanimationcallbackstate()
+  local anim = gfx.newgridanimation(gfx.newimage("icon.png"), 120, 111, nil, 0.1)
+  local frames, threads = {}, {}
+  local setter = coroutine.create(function()
+    anim:onframe(function(frame)
+      table.insert(frames, frame)
+      table.insert(threads, coroutine.running())
+    end)
+    coroutine.yield()
+  end)
+  test.istrue(coroutine.resume(setter))
+  anim:update(0.25)
+  test.equal(table.concat(frames, ","), "1,2")
+  test.equal(threads[1], coroutine.running())
+  test.istrue(coroutine.resume(setter))
+  test.equal(coroutine.status(setter), "dead")
+end
+
+function testanimationjson()
+  local anim, err = gfx.newanimationfromjson("animation.json")
+  test.equal(err, nil)
+  test.equal(anim:getframecount(), 4)
+  test.equal(table.concat(anim:gettags(), ","), "idle,spin")
+  test.near(anim:getduration(1), 0.2)
+  anim:settag("idle")
+  anim:update(0.15)
+  test.equal(anim:getframe(), 1)
+  local _, missing = gfx.newanimationfromjson("missing.json")
+  test.istrue(missing ~= nil)
+end
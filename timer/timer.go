@@ -0,0 +1,175 @@
+// Package timer keeps track of game time and schedules lua callbacks and tweens
+// to be run as the game loop advances.
+package timer
+
+import (
+	"github.com/goxjs/glfw"
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/runtime"
+)
+
+// Timer is a scheduled callback or tween that is advanced every update.
+type Timer struct {
+	time     float32    // time elapsed since the timer started or last fired
+	delay    float32    // time until the timer fires or the tween completes
+	count    int        // times left to fire, -1 for forever
+	callback lua.LValue // function to call when the timer fires
+	tween    *tween
+	active   bool
+}
+
+var (
+	ls           *lua.LState
+	timers       []*Timer
+	currentTime  float32 // total game time
+	currentDelta float32 // last timestep
+	averageDelta float32 // average timestep over the last second
+	deltaTotal   float32 // total time since the average was last updated
+	deltaFrames  int     // updates since the average was last updated
+)
+
+func init() {
+	runtime.RegisterModule("timer", timerFunctions, timerMetaTables)
+	runtime.RegisterUpdateHook(update)
+	runtime.RegisterSaveHook(func() func() {
+		savedLS, savedTimers := ls, timers
+		savedTime, savedDelta, savedAverage := currentTime, currentDelta, averageDelta
+		savedTotal, savedFrames := deltaTotal, deltaFrames
+		return func() {
+			ls, timers = savedLS, savedTimers
+			currentTime, currentDelta, averageDelta = savedTime, savedDelta, savedAverage
+			deltaTotal, deltaFrames = savedTotal, savedFrames
+		}
+	})
+	runtime.RegisterHook(func(state *lua.LState, window *glfw.Window) {
+		ls = state
+		timers = []*Timer{}
+		currentTime, currentDelta, averageDelta = 0, 0, 0
+		deltaTotal, deltaFrames = 0, 0
+	})
+}
+
+// GetTime returns the total game time in seconds. This is the sum of all the
+// timesteps passed to update so it will be the same when a run is replayed.
+func GetTime() float32 {
+	return currentTime
+}
+
+// GetDelta returns the timestep of the last update.
+func GetDelta() float32 {
+	return currentDelta
+}
+
+// GetAverageDelta returns the average timestep over the last second.
+func GetAverageDelta() float32 {
+	return averageDelta
+}
+
+// After will call the callback once after delay seconds.
+func After(delay float32, callback lua.LValue) *Timer {
+	return schedule(&Timer{delay: delay, count: 1, callback: callback})
+}
+
+// Every will call the callback every delay seconds, count times. If count is
+// less than 1 the callback will be called until the timer is cancelled.
+func Every(delay float32, callback lua.LValue, count int) *Timer {
+	if count < 1 {
+		count = -1
+	}
+	return schedule(&Timer{delay: delay, count: count, callback: callback})
+}
+
+// Clear will cancel all of the scheduled timers and tweens.
+func Clear() {
+	for _, timer := range timers {
+		timer.active = false
+	}
+	timers = []*Timer{}
+}
+
+// Cancel will stop the timer from firing again.
+func (timer *Timer) Cancel() {
+	timer.active = false
+}
+
+// IsActive will return true if the timer has not been cancelled or finished.
+func (timer *Timer) IsActive() bool {
+	return timer.active
+}
+
+func schedule(timer *Timer) *Timer {
+	timer.active = true
+	timers = append(timers, timer)
+	return timer
+}
+
+func update(dt float32) error {
+	currentTime += dt
+	currentDelta = dt
+	deltaTotal += dt
+	deltaFrames++
+	if deltaTotal >= 1 {
+		averageDelta = deltaTotal / float32(deltaFrames)
+		deltaTotal, deltaFrames = 0, 0
+	}
+
+	// callbacks may schedule new timers so only the ones that exist now are updated
+	for _, timer := range timers {
+		if err := timer.update(dt); err != nil {
+			return err
+		}
+	}
+
+	active := timers[:0]
+	for _, timer := range timers {
+		if timer.active {
+			active = append(active, timer)
+		}
+	}
+	timers = active
+	return nil
+}
+
+func (timer *Timer) update(dt float32) error {
+	if !timer.active {
+		return nil
+	}
+	timer.time += dt
+
+	if timer.tween != nil {
+		progress := float32(1)
+		if timer.delay > 0 && timer.time < timer.delay {
+			progress = timer.time / timer.delay
+		}
+		timer.tween.apply(progress)
+		if progress < 1 {
+			return nil
+		}
+		timer.active = false
+		return timer.call()
+	}
+
+	for timer.active && timer.time >= timer.delay {
+		timer.time -= timer.delay
+		if timer.count > 0 {
+			timer.count--
+			timer.active = timer.count > 0
+		}
+		if err := timer.call(); err != nil {
+			return err
+		}
+		if timer.delay <= 0 {
+			timer.time = 0 // fire at most once per update if there is no delay
+			break
+		}
+	}
+	return nil
+}
+
+func (timer *Timer) call() error {
+	if timer.callback == nil || timer.callback == lua.LNil {
+		return nil
+	}
+	return ls.CallByParam(lua.P{Fn: timer.callback, Protect: true})
+}
@@ -0,0 +1,151 @@
+package timer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/yuin/gopher-lua"
+)
+
+// EaseFunc maps the progress of a tween from 0 to 1 into the eased progress
+type EaseFunc func(t float64) float64
+
+// tween animates the numeric fields of a lua table from thier starting values
+// to the target values
+type tween struct {
+	ease   EaseFunc
+	fields []tweenField
+}
+
+type tweenField struct {
+	subject    *lua.LTable
+	key        lua.LValue
+	start, end float64
+}
+
+var easings = map[string]EaseFunc{
+	"linear": func(t float64) float64 { return t },
+	"quad":   func(t float64) float64 { return t * t },
+	"cubic":  func(t float64) float64 { return t * t * t },
+	"quart":  func(t float64) float64 { return t * t * t * t },
+	"quint":  func(t float64) float64 { return t * t * t * t * t },
+	"sine":   func(t float64) float64 { return 1 - math.Cos(t*math.Pi/2) },
+	"expo": func(t float64) float64 {
+		if t == 0 {
+			return 0
+		}
+		return math.Pow(2, 10*(t-1))
+	},
+	"circ": func(t float64) float64 { return 1 - math.Sqrt(1-t*t) },
+	"back": func(t float64) float64 { return t * t * (2.70158*t - 1.70158) },
+	"elastic": func(t float64) float64 {
+		if t == 0 || t == 1 {
+			return t
+		}
+		return -math.Pow(2, 10*(t-1)) * math.Sin((t-1.075)*(2*math.Pi)/0.3)
+	},
+	"bounce": func(t float64) float64 { return 1 - bounceOut(1-t) },
+}
+
+func bounceOut(t float64) float64 {
+	switch {
+	case t < 1/2.75:
+		return 7.5625 * t * t
+	case t < 2/2.75:
+		t -= 1.5 / 2.75
+		return 7.5625*t*t + 0.75
+	case t < 2.5/2.75:
+		t -= 2.25 / 2.75
+		return 7.5625*t*t + 0.9375
+	default:
+		t -= 2.625 / 2.75
+		return 7.5625*t*t + 0.984375
+	}
+}
+
+// GetEasing will return the easing function for the name given. Every curve
+// other than linear is available as in, out and inout, for example inquad,
+// outquad and inoutquad. A curve without a prefix will ease in.
+func GetEasing(name string) (EaseFunc, error) {
+	if ease, ok := easings[name]; ok {
+		return ease, nil
+	}
+	for _, prefix := range []string{"inout", "in", "out"} {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if ease, ok := easings[strings.TrimPrefix(name, prefix)]; ok {
+			return easeVariants[prefix](ease), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown easing %v", name)
+}
+
+var easeVariants = map[string]func(EaseFunc) EaseFunc{
+	"in":    easeIn,
+	"out":   easeOut,
+	"inout": easeInOut,
+}
+
+func easeIn(ease EaseFunc) EaseFunc { return ease }
+
+func easeOut(ease EaseFunc) EaseFunc {
+	return func(t float64) float64 { return 1 - ease(1-t) }
+}
+
+func easeInOut(ease EaseFunc) EaseFunc {
+	return func(t float64) float64 {
+		if t < 0.5 {
+			return ease(t*2) / 2
+		}
+		return 1 - ease((1-t)*2)/2
+	}
+}
+
+// Tween will animate the numeric fields of the subject table to the values in
+// the target table over duration seconds. Nested tables in the target will tween
+// the matching tables in the subject. The callback is called when the tween
+// has completed.
+func Tween(duration float32, subject, target *lua.LTable, ease EaseFunc, callback lua.LValue) (*Timer, error) {
+	tw := &tween{ease: ease}
+	if err := tw.collect(subject, target); err != nil {
+		return nil, err
+	}
+	return schedule(&Timer{delay: duration, count: 1, callback: callback, tween: tw}), nil
+}
+
+func (tw *tween) collect(subject, target *lua.LTable) error {
+	var err error
+	target.ForEach(func(key, value lua.LValue) {
+		if err != nil {
+			return
+		}
+		switch end := value.(type) {
+		case lua.LNumber:
+			start, ok := subject.RawGet(key).(lua.LNumber)
+			if !ok {
+				err = fmt.Errorf("field %v is not a number in the tweened table", key)
+				return
+			}
+			tw.fields = append(tw.fields, tweenField{subject: subject, key: key, start: float64(start), end: float64(end)})
+		case *lua.LTable:
+			nested, ok := subject.RawGet(key).(*lua.LTable)
+			if !ok {
+				err = fmt.Errorf("field %v is not a table in the tweened table", key)
+				return
+			}
+			err = tw.collect(nested, end)
+		default:
+			err = fmt.Errorf("field %v should be a number or a table", key)
+		}
+	})
+	return err
+}
+
+func (tw *tween) apply(progress float32) {
+	eased := tw.ease(float64(progress))
+	for _, field := range tw.fields {
+		field.subject.RawSet(field.key, lua.LNumber(field.start+(field.end-field.start)*eased))
+	}
+}
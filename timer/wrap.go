@@ -0,0 +1,96 @@
+package timer
+
+import (
+	"github.com/yuin/gopher-lua"
+
+	"github.com/tanema/amore/runtime"
+)
+
+var timerFunctions = runtime.LuaFuncs{
+	"gettime":         timerGetTime,
+	"getdelta":        timerGetDelta,
+	"getaveragedelta": timerGetAverageDelta,
+	"after":           timerAfter,
+	"every":           timerEvery,
+	"tween":           timerTween,
+	"cancel":          timerCancel,
+	"clear":           timerClear,
+}
+
+var timerMetaTables = runtime.LuaMetaTable{
+	"Timer": {
+		"cancel":   timerCancel,
+		"isactive": timerIsActive,
+	},
+}
+
+func toTimer(ls *lua.LState, offset int) *Timer {
+	timer := ls.CheckUserData(offset)
+	if v, ok := timer.Value.(*Timer); ok {
+		return v
+	}
+	ls.ArgError(offset, "timer expected")
+	return nil
+}
+
+func returnTimer(ls *lua.LState, timer *Timer) int {
+	f := ls.NewUserData()
+	f.Value = timer
+	ls.SetMetatable(f, ls.GetTypeMetatable("Timer"))
+	ls.Push(f)
+	return 1
+}
+
+func timerGetTime(ls *lua.LState) int {
+	ls.Push(lua.LNumber(GetTime()))
+	return 1
+}
+
+func timerGetDelta(ls *lua.LState) int {
+	ls.Push(lua.LNumber(GetDelta()))
+	return 1
+}
+
+func timerGetAverageDelta(ls *lua.LState) int {
+	ls.Push(lua.LNumber(GetAverageDelta()))
+	return 1
+}
+
+func timerAfter(ls *lua.LState) int {
+	return returnTimer(ls, After(float32(ls.CheckNumber(1)), ls.CheckFunction(2)))
+}
+
+func timerEvery(ls *lua.LState) int {
+	return returnTimer(ls, Every(float32(ls.CheckNumber(1)), ls.CheckFunction(2), ls.OptInt(3, 0)))
+}
+
+func timerTween(ls *lua.LState) int {
+	ease, err := GetEasing(ls.OptString(4, "linear"))
+	if err != nil {
+		ls.ArgError(4, err.Error())
+	}
+	var callback lua.LValue = lua.LNil
+	if ls.GetTop() >= 5 {
+		callback = ls.CheckFunction(5)
+	}
+	timer, err := Tween(float32(ls.CheckNumber(1)), ls.CheckTable(2), ls.CheckTable(3), ease, callback)
+	if err != nil {
+		ls.ArgError(3, err.Error())
+	}
+	return returnTimer(ls, timer)
+}
+
+func timerCancel(ls *lua.LState) int {
+	toTimer(ls, 1).Cancel()
+	return 0
+}
+
+func timerClear(ls *lua.LState) int {
+	Clear()
+	return 0
+}
+
+func timerIsActive(ls *lua.LState) int {
+	ls.Push(lua.LBool(toTimer(ls, 1).IsActive()))
+	return 1
+}